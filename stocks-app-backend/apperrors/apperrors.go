@@ -0,0 +1,151 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Kind classifies an error for clients. Its value is the machine-readable
+// code returned in error responses.
+type Kind string
+
+const (
+	KindValidation    Kind = "validation_error"
+	KindUnprocessable Kind = "unprocessable"
+	KindUnauthorized  Kind = "unauthorized"
+	KindForbidden     Kind = "forbidden"
+	KindNotFound      Kind = "not_found"
+	KindConflict      Kind = "conflict"
+	KindRateLimited   Kind = "rate_limited"
+	KindUnavailable   Kind = "upstream_unavailable"
+	KindTimeout       Kind = "timeout"
+	KindInternal      Kind = "internal_error"
+)
+
+func (k Kind) Status() int {
+	switch k {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindRateLimited:
+		return http.StatusTooManyRequests
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error with a kind that tells the HTTP layer how to report it.
+// Message is shown to clients, Err is the underlying cause and is only logged.
+// Data, when set, is returned with the error, e.g. what an operation that
+// failed part way managed to do.
+type Error struct {
+	Kind    Kind
+	Message string
+	Fields  []FieldError
+	Data    interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func Validation(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+// InvalidField is a validation error about a single request field.
+func InvalidField(field, message string) *Error {
+	return Validation(message, FieldError{Field: field, Message: message})
+}
+
+func NotFound(message string) *Error {
+	return New(KindNotFound, message)
+}
+
+func Conflict(message string, err error) *Error {
+	return Wrap(KindConflict, message, err)
+}
+
+func Unavailable(message string, err error) *Error {
+	return Wrap(KindUnavailable, message, err)
+}
+
+func Timeout(message string, err error) *Error {
+	return Wrap(KindTimeout, message, err)
+}
+
+// WithData returns a copy of err as an *Error that carries data to the client.
+func WithData(err error, data interface{}) *Error {
+	withData := *From(err)
+	withData.Data = data
+	return &withData
+}
+
+// From returns err as an *Error. Well-known causes that were not typed where
+// they happened (timeouts, missing records, unique violations) get their kind
+// here, anything else is an internal error whose details stay in the logs.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout("the request took too long", err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return Wrap(KindNotFound, "resource not found", err)
+	}
+
+	if isUniqueViolation(err) {
+		return Conflict("resource already exists", err)
+	}
+
+	return Wrap(KindInternal, "internal server error", err)
+}
+
+func isUniqueViolation(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "SQLSTATE 23505") ||
+		strings.Contains(msg, "duplicate key value") ||
+		strings.Contains(msg, "UNIQUE constraint failed")
+}
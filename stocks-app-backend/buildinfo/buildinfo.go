@@ -0,0 +1,50 @@
+package buildinfo
+
+import "runtime/debug"
+
+// Set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/felipepalacio293/stocks-app/buildinfo.Version=1.4.0 -X github.com/felipepalacio293/stocks-app/buildinfo.Commit=$(git rev-parse HEAD)"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+}
+
+// Get returns the link-time values, falling back to the VCS data the Go
+// toolchain embeds when the binary was built without ldflags.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = bi.GoVersion
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry is a rendered response body together with its validator.
+type Entry struct {
+	ContentType string
+	Body        []byte
+	ETag        string
+	storedAt    time.Time
+}
+
+// ResponseCache keeps rendered API responses in memory. Entries belong to a
+// data version that is bumped whenever stocks or prices change, so a sync
+// run makes every earlier entry stale at once. The TTL bounds staleness for
+// writes this process doesn't see, such as other replicas or stocksctl.
+type ResponseCache struct {
+	mu         sync.RWMutex
+	version    uint64
+	entries    map[string]*Entry
+	maxEntries int
+	ttl        time.Duration
+}
+
+func NewResponseCache(maxEntries int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		entries:    make(map[string]*Entry),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+func (c *ResponseCache) Version() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// Invalidate drops every entry. It is safe to call on a nil cache.
+func (c *ResponseCache) Invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	c.entries = make(map[string]*Entry)
+}
+
+func (c *ResponseCache) Get(key string) (*Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok || (c.ttl > 0 && time.Since(entry.storedAt) > c.ttl) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Set stores entry if the data hasn't changed since version was read, so a
+// response computed while a sync was writing is never cached.
+func (c *ResponseCache) Set(key string, version uint64, entry *Entry) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.version {
+		return
+	}
+
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= c.maxEntries {
+		// Queries are mostly the same handful of pages and filters, evicting
+		// an arbitrary entry is good enough
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+
+	entry.storedAt = time.Now()
+	c.entries[key] = entry
+}
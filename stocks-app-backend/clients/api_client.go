@@ -2,22 +2,30 @@ package clients
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/felipepalacio293/stocks-app/metrics"
 	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/tracing"
+	"github.com/felipepalacio293/stocks-app/utils"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("github.com/felipepalacio293/stocks-app/clients")
+
+// APISourceName is the rating source name of the challenge API.
+const APISourceName = "swechallenge"
+
 type APIClient struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
+	onPage     func(page, items int)
 }
 
 func NewAPIClient(baseURL, apiKey string) *APIClient {
@@ -30,6 +38,22 @@ func NewAPIClient(baseURL, apiKey string) *APIClient {
 	}
 }
 
+// SetTransport replaces the HTTP transport, used to record and replay
+// upstream responses.
+func (c *APIClient) SetTransport(transport http.RoundTripper) {
+	c.httpClient.Transport = transport
+}
+
+func (c *APIClient) Name() string {
+	return APISourceName
+}
+
+// OnPage registers fn to be called after each page is fetched, for progress
+// reporting.
+func (c *APIClient) OnPage(fn func(page, items int)) {
+	c.onPage = fn
+}
+
 type StockResponse struct {
 	Status   string      `json:"status"`
 	Items    []StockData `json:"items"`
@@ -48,53 +72,42 @@ type StockData struct {
 	Time       string `json:"time"`
 }
 
-func (c *APIClient) FetchStocks(ctx context.Context) ([]models.Stock, error) {
+func (c *APIClient) FetchStocks(ctx context.Context) (_ []models.Stock, err error) {
+	ctx, span := tracer.Start(ctx, "APIClient.FetchStocks")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	stocks := []models.Stock{}
 	nextPage := ""
 	baseEndpoint := fmt.Sprintf("%s/production/swechallenge/list", c.baseURL)
 
-	for {
+	for page := 1; ; page++ {
 		url := baseEndpoint
 		if nextPage != "" {
 			url = fmt.Sprintf("%s?next_page=%s", baseEndpoint, nextPage)
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("error creating request: %w", err)
-		}
-
-		req.Header.Add("Accept", "application/json")
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
-
-		resp, err := c.httpClient.Do(req)
+		stockResp, err := c.fetchPage(ctx, url, page)
 		if err != nil {
-			return nil, fmt.Errorf("error executing request: %w", err)
-		}
-
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("API returned non-OK status: %d", resp.StatusCode)
-		}
-
-		var stockResp StockResponse
-		if err := json.NewDecoder(resp.Body).Decode(&stockResp); err != nil {
-			return nil, fmt.Errorf("error decoding response: %w", err)
+			return nil, err
 		}
 
 		for _, data := range stockResp.Items {
-			cleanTargetFrom := strings.ReplaceAll(strings.TrimPrefix(data.TargetFrom, "$"), ",", "")
-			targetFrom, err := strconv.ParseFloat(cleanTargetFrom, 64)
+			targetFrom, err := utils.ParsePrice(data.TargetFrom)
 			if err != nil {
-				log.Printf("Warning: could not parse TargetFrom value '%s' for ticker %s: %v", data.TargetFrom, data.Ticker, err)
+				slog.WarnContext(ctx, "Could not parse TargetFrom value",
+					slog.String("value", data.TargetFrom), slog.String("ticker", data.Ticker), slog.Any("error", err))
+				metrics.SyncParseFailuresTotal.WithLabelValues("target_from").Inc()
 				continue
 			}
 
-			cleanTargetTo := strings.ReplaceAll(strings.TrimPrefix(data.TargetTo, "$"), ",", "")
-			targetTo, err := strconv.ParseFloat(cleanTargetTo, 64)
+			targetTo, err := utils.ParsePrice(data.TargetTo)
 			if err != nil {
-				log.Printf("Warning: could not parse TargetTo value '%s' for ticker %s: %v", data.TargetTo, data.Ticker, err)
+				slog.WarnContext(ctx, "Could not parse TargetTo value",
+					slog.String("value", data.TargetTo), slog.String("ticker", data.Ticker), slog.Any("error", err))
+				metrics.SyncParseFailuresTotal.WithLabelValues("target_to").Inc()
 				continue
 			}
 
@@ -109,9 +122,24 @@ func (c *APIClient) FetchStocks(ctx context.Context) ([]models.Stock, error) {
 				TargetFrom: targetFrom,
 				TargetTo:   targetTo,
 			}
+			// A rating without a usable time is still synced, stamped when it
+			// is stored instead
+			if data.Time != "" {
+				ratedAt, err := time.Parse(time.RFC3339Nano, data.Time)
+				if err != nil {
+					slog.WarnContext(ctx, "Could not parse Time value",
+						slog.String("value", data.Time), slog.String("ticker", data.Ticker), slog.Any("error", err))
+				} else {
+					stock.RatedAt = &ratedAt
+				}
+			}
 			stocks = append(stocks, stock)
 		}
 
+		if c.onPage != nil {
+			c.onPage(page, len(stockResp.Items))
+		}
+
 		if stockResp.NextPage == "" || len(stockResp.Items) == 0 {
 			break
 		}
@@ -120,9 +148,49 @@ func (c *APIClient) FetchStocks(ctx context.Context) ([]models.Stock, error) {
 
 		time.Sleep(100 * time.Millisecond)
 
-		log.Printf("Fetched page with %d items. Next page token: %s", len(stockResp.Items), nextPage)
+		slog.DebugContext(ctx, "Fetched page", slog.Int("page", page), slog.Int("items", len(stockResp.Items)), slog.String("next_page", nextPage))
 	}
 
-	log.Printf("Successfully fetched a total of %d stocks", len(stocks))
+	span.SetAttributes(attribute.Int("stocks.count", len(stocks)))
+	slog.InfoContext(ctx, "Successfully fetched stocks", slog.Int("total", len(stocks)))
 	return stocks, nil
 }
+
+// Ping checks that the upstream API answers.
+func (c *APIClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/production/swechallenge/list", c.baseURL), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+	return ping(ctx, c.httpClient, req)
+}
+
+func (c *APIClient) fetchPage(ctx context.Context, url string, page int) (_ *StockResponse, err error) {
+	ctx, span := tracer.Start(ctx, "APIClient.fetchPage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("upstream.page", page),
+			attribute.String("http.request.method", "GET"),
+		))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+	var stockResp StockResponse
+	if err := getJSON(ctx, c.httpClient, req, "stock API", &stockResp); err != nil {
+		return nil, err
+	}
+	metrics.SyncPagesTotal.WithLabelValues(APISourceName).Inc()
+	span.SetAttributes(attribute.Int("upstream.items", len(stockResp.Items)))
+
+	return &stockResp, nil
+}
@@ -0,0 +1,67 @@
+package clients
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testdata/upstream holds a three page sync recorded by RecordingTransport,
+// chained by next_page AKBA and BSBR, with one unparseable target price.
+func TestAPIClientFetchStocksReplaysPageChain(t *testing.T) {
+	client := NewAPIClient("http://upstream.invalid", "test-key")
+	client.SetTransport(NewReplayTransport("testdata/upstream"))
+
+	var itemsPerPage []int
+	client.OnPage(func(page, items int) {
+		itemsPerPage = append(itemsPerPage, items)
+	})
+
+	stocks, err := client.FetchStocks(context.Background())
+	if err != nil {
+		t.Fatalf("FetchStocks: %v", err)
+	}
+
+	if want := []int{2, 2, 1}; !slices.Equal(itemsPerPage, want) {
+		t.Errorf("items per page = %v, want %v", itemsPerPage, want)
+	}
+
+	type stockFields struct {
+		ticker, company, brokerage, action, ratingFrom, ratingTo string
+		targetFrom, targetTo                                     float64
+		ratedAt                                                  string
+	}
+	// BSBR is skipped, its target_to is N/A
+	want := []stockFields{
+		{"AAPL", "Apple Inc.", "Morgan Stanley", "target raised by", "Overweight", "Overweight", 220, 245, "2026-01-14T00:30:05.813548892Z"},
+		{"AKBA", "Akebia Therapeutics", "HC Wainwright", "reiterated by", "Buy", "Buy", 4, 4, "2026-01-13T00:30:05.813548892Z"},
+		{"BRK.B", "Berkshire Hathaway Inc.", "UBS Group", "upgraded by", "Neutral", "Buy", 480, 1050.50, "2026-01-12T00:30:05.813548892Z"},
+		{"MSFT", "Microsoft Corporation", "Barclays", "initiated by", "", "Overweight", 0, 550, "2026-01-11T00:30:05.813548892Z"},
+	}
+	if len(stocks) != len(want) {
+		t.Fatalf("got %d stocks, want %d", len(stocks), len(want))
+	}
+	for i, stock := range stocks {
+		var ratedAt string
+		if stock.RatedAt != nil {
+			ratedAt = stock.RatedAt.Format(time.RFC3339Nano)
+		}
+		got := stockFields{stock.Ticker, stock.Company, stock.Brokerage, stock.Action, stock.RatingFrom, stock.RatingTo, stock.TargetFrom, stock.TargetTo, ratedAt}
+		if got != want[i] {
+			t.Errorf("stock %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestAPIClientFetchStocksFailsOnMissingPage(t *testing.T) {
+	dir := t.TempDir()
+	client := NewAPIClient("http://upstream.invalid", "test-key")
+	client.SetTransport(NewReplayTransport(dir))
+
+	_, err := client.FetchStocks(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no recorded fixture") {
+		t.Fatalf("FetchStocks error = %v, want a missing fixture error", err)
+	}
+}
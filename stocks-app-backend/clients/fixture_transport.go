@@ -0,0 +1,184 @@
+package clients
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Fixture is one recorded upstream response. NextPage is the cursor the
+// request asked for and the body holds the next_page it handed out, so a
+// fixtures directory keeps the whole page chain of a sync.
+type Fixture struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	NextPage    string          `json:"next_page,omitempty"`
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	RecordedAt  time.Time       `json:"recorded_at"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	// Bodies that are not JSON, like proxy error pages, are kept as text
+	Text string `json:"text,omitempty"`
+}
+
+var unsafeFixtureChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// FixtureName is the file a response to req is recorded in: the last path
+// segment plus the next_page cursor, e.g. list.json and list_AAPL.json.
+func FixtureName(req *http.Request) string {
+	name := path.Base(req.URL.Path)
+	if nextPage := req.URL.Query().Get("next_page"); nextPage != "" {
+		safe := unsafeFixtureChars.ReplaceAllString(nextPage, "_")
+		if safe != nextPage {
+			// Keeps cursors that only differ in replaced characters apart
+			sum := sha256.Sum256([]byte(nextPage))
+			safe += "-" + hex.EncodeToString(sum[:4])
+		}
+		name += "_" + safe
+	}
+	return name + ".json"
+}
+
+// RecordingTransport passes requests to next and saves every response it
+// gets into dir, overwriting older recordings of the same page.
+type RecordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func NewRecordingTransport(dir string, next http.RoundTripper) *RecordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RecordingTransport{
+		dir:  dir,
+		next: next,
+	}
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response to record: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := Fixture{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		NextPage:    req.URL.Query().Get("next_page"),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		RecordedAt:  time.Now().UTC(),
+	}
+	if json.Valid(body) {
+		fixture.Body = body
+	} else {
+		fixture.Text = string(body)
+	}
+
+	// A failed recording should not fail the sync it is watching
+	if err := t.write(FixtureName(req), fixture); err != nil {
+		slog.ErrorContext(req.Context(), "Error recording upstream response",
+			slog.String("dir", t.dir), slog.String("path", req.URL.RequestURI()), slog.Any("error", err))
+	}
+
+	return resp, nil
+}
+
+func (t *RecordingTransport) write(name string, fixture Fixture) error {
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating fixtures directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding fixture: %w", err)
+	}
+
+	// Written aside and renamed so a replay never reads half a file
+	tmp, err := os.CreateTemp(t.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating fixture file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing fixture file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing fixture file: %w", err)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(t.dir, name))
+}
+
+// ReplayTransport answers requests with the fixtures a RecordingTransport
+// saved in dir, without touching the network.
+type ReplayTransport struct {
+	dir string
+}
+
+func NewReplayTransport(dir string) *ReplayTransport {
+	return &ReplayTransport{dir: dir}
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	file := filepath.Join(t.dir, FixtureName(req))
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no recorded fixture for %s %s, expected %s", req.Method, req.URL.RequestURI(), file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("error decoding fixture %s: %w", file, err)
+	}
+
+	body := []byte(fixture.Text)
+	if len(fixture.Body) > 0 {
+		body = fixture.Body
+	}
+
+	header := http.Header{}
+	if fixture.ContentType != "" {
+		header.Set("Content-Type", fixture.ContentType)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Status, http.StatusText(fixture.Status)),
+		StatusCode:    fixture.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/metrics"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// getJSON sends req and decodes a 200 JSON response into out. It propagates the
+// request ID and trace context and records the upstream metrics shared by every
+// HTTP rating source.
+func getJSON(ctx context.Context, httpClient *http.Client, req *http.Request, vendor string, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	if requestID := utils.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(utils.RequestIDHeader, requestID)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	metrics.UpstreamRequestDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.UpstreamRequestsTotal.WithLabelValues("error").Inc()
+		return apperrors.Unavailable(vendor+" is unreachable", err)
+	}
+
+	defer resp.Body.Close()
+	metrics.UpstreamRequestsTotal.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		return apperrors.Unavailable(fmt.Sprintf("%s returned non-OK status: %d", vendor, resp.StatusCode), nil)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// ping reports whether the upstream answers req. Any response below 500
+// counts as reachable, auth or quota problems show up in the sync itself.
+func ping(ctx context.Context, httpClient *http.Client, req *http.Request) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("API returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
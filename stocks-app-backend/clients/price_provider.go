@@ -0,0 +1,167 @@
+package clients
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/utils"
+)
+
+// PriceProvider is a source of daily OHLCV bars.
+type PriceProvider interface {
+	FetchPrices(ctx context.Context) ([]models.Price, error)
+}
+
+var priceDateLayouts = []string{"2006-01-02", "01/02/2006", "20060102"}
+
+// CSVPriceProvider reads every *.csv file in a directory. Files either carry a
+// ticker column or are named after the ticker (e.g. AAPL.csv), and need at
+// least date and close columns; open, high, low and volume are optional.
+type CSVPriceProvider struct {
+	dir string
+}
+
+func NewCSVPriceProvider(dir string) *CSVPriceProvider {
+	return &CSVPriceProvider{dir: dir}
+}
+
+func (p *CSVPriceProvider) FetchPrices(ctx context.Context) ([]models.Price, error) {
+	files, err := filepath.Glob(filepath.Join(p.dir, "*.csv"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing price files: %w", err)
+	}
+
+	prices := []models.Price{}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		filePrices, err := readPriceFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", file, err)
+		}
+		prices = append(prices, filePrices...)
+	}
+
+	slog.InfoContext(ctx, "Loaded price bars", slog.Int("bars", len(prices)), slog.Int("files", len(files)), slog.String("dir", p.dir))
+	return prices, nil
+}
+
+func readPriceFile(path string) ([]models.Price, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadPricesCSV(f, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+}
+
+// ReadPricesCSV parses a price CSV. defaultTicker is used when the file has
+// no ticker column.
+func ReadPricesCSV(r io.Reader, defaultTicker string) ([]models.Price, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{"date", "close"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	value := func(record []string, column string) string {
+		idx, ok := columns[column]
+		if !ok || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+
+	prices := []models.Price{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		ticker := strings.ToUpper(value(record, "ticker"))
+		if ticker == "" {
+			ticker = strings.ToUpper(defaultTicker)
+		}
+
+		date, err := parsePriceDate(value(record, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		closePrice, err := utils.ParsePrice(value(record, "close"))
+		if err != nil {
+			// Vendors use "null" or empty cells for halted days
+			slog.Warn("Skipping price bar with invalid close",
+				slog.String("ticker", ticker), slog.String("date", date.Format("2006-01-02")), slog.String("close", value(record, "close")))
+			continue
+		}
+
+		price := models.Price{
+			Ticker: ticker,
+			Date:   date,
+			Open:   parseOptionalPrice(value(record, "open")),
+			High:   parseOptionalPrice(value(record, "high")),
+			Low:    parseOptionalPrice(value(record, "low")),
+			Close:  closePrice,
+		}
+
+		if volume := value(record, "volume"); volume != "" {
+			price.Volume, _ = strconv.ParseInt(strings.ReplaceAll(volume, ",", ""), 10, 64)
+		}
+
+		prices = append(prices, price)
+	}
+
+	return prices, nil
+}
+
+func parsePriceDate(value string) (time.Time, error) {
+	for _, layout := range priceDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func parseOptionalPrice(value string) float64 {
+	price, err := utils.ParsePrice(value)
+	if err != nil {
+		return 0
+	}
+	return price
+}
@@ -0,0 +1,172 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/metrics"
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// VendorSourceName is the rating source name of the second vendor API.
+const VendorSourceName = "vendor"
+
+// VendorClient reads analyst ratings from a JSON API paginated by page number:
+//
+//	GET {baseURL}/v1/ratings?page=1   (X-API-Key: {apiKey})
+//	{"data": [{"symbol": "AAPL", "company_name": "Apple Inc.", "firm": "...",
+//	  "action": "upgraded", "rating_prior": "Hold", "rating_current": "Buy",
+//	  "price_target_prior": 180, "price_target_current": 210}],
+//	 "meta": {"page": 1, "total_pages": 4}}
+//
+// Targets are numbers, items without a current target are skipped.
+type VendorClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	onPage     func(page, items int)
+}
+
+func NewVendorClient(baseURL, apiKey string) *VendorClient {
+	return &VendorClient{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+type vendorResponse struct {
+	Data []vendorRating `json:"data"`
+	Meta struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"meta"`
+}
+
+type vendorRating struct {
+	Symbol             string   `json:"symbol"`
+	CompanyName        string   `json:"company_name"`
+	Firm               string   `json:"firm"`
+	Action             string   `json:"action"`
+	RatingPrior        string   `json:"rating_prior"`
+	RatingCurrent      string   `json:"rating_current"`
+	PriceTargetPrior   *float64 `json:"price_target_prior"`
+	PriceTargetCurrent *float64 `json:"price_target_current"`
+}
+
+func (c *VendorClient) Name() string {
+	return VendorSourceName
+}
+
+// OnPage registers fn to be called after each page is fetched, for progress
+// reporting.
+func (c *VendorClient) OnPage(fn func(page, items int)) {
+	c.onPage = fn
+}
+
+func (c *VendorClient) FetchStocks(ctx context.Context) (_ []models.Stock, err error) {
+	ctx, span := tracer.Start(ctx, "VendorClient.FetchStocks")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	stocks := []models.Stock{}
+	for page := 1; ; page++ {
+		resp, err := c.fetchPage(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Data {
+			if item.PriceTargetCurrent == nil {
+				metrics.SyncParseFailuresTotal.WithLabelValues("target_to").Inc()
+				continue
+			}
+
+			stock := models.Stock{
+				ID:         uuid.New(),
+				Ticker:     item.Symbol,
+				Company:    item.CompanyName,
+				Brokerage:  item.Firm,
+				Action:     item.Action,
+				RatingFrom: item.RatingPrior,
+				RatingTo:   item.RatingCurrent,
+				TargetTo:   *item.PriceTargetCurrent,
+			}
+			// An initiation has no prior target, treat it as unchanged
+			stock.TargetFrom = stock.TargetTo
+			if item.PriceTargetPrior != nil {
+				stock.TargetFrom = *item.PriceTargetPrior
+			}
+			stocks = append(stocks, stock)
+		}
+
+		if c.onPage != nil {
+			c.onPage(page, len(resp.Data))
+		}
+
+		if page >= resp.Meta.TotalPages || len(resp.Data) == 0 {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	span.SetAttributes(attribute.Int("stocks.count", len(stocks)))
+	slog.InfoContext(ctx, "Successfully fetched vendor ratings", slog.Int("total", len(stocks)))
+	return stocks, nil
+}
+
+func (c *VendorClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.pageURL(1), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	return ping(ctx, c.httpClient, req)
+}
+
+func (c *VendorClient) fetchPage(ctx context.Context, page int) (_ *vendorResponse, err error) {
+	ctx, span := tracer.Start(ctx, "VendorClient.fetchPage",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("upstream.page", page),
+			attribute.String("http.request.method", "GET"),
+		))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.pageURL(page), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+
+	var resp vendorResponse
+	if err := getJSON(ctx, c.httpClient, req, "vendor API", &resp); err != nil {
+		return nil, err
+	}
+	metrics.SyncPagesTotal.WithLabelValues(VendorSourceName).Inc()
+	span.SetAttributes(attribute.Int("upstream.items", len(resp.Data)))
+
+	return &resp, nil
+}
+
+func (c *VendorClient) pageURL(page int) string {
+	return fmt.Sprintf("%s/v1/ratings?%s", c.baseURL, url.Values{"page": {strconv.Itoa(page)}}.Encode())
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/clients"
+)
+
+var brokerages = []string{
+	"Goldman Sachs", "Morgan Stanley", "JPMorgan Chase & Co.", "Bank of America", "Citigroup",
+	"Wells Fargo & Company", "Barclays", "UBS Group", "Deutsche Bank", "Jefferies Financial Group",
+	"Raymond James", "Piper Sandler", "Needham & Company LLC", "Wedbush", "Oppenheimer",
+	"Stifel Nicolaus", "KeyCorp", "Truist Financial", "Evercore ISI", "BMO Capital Markets",
+	"Royal Bank of Canada", "Benchmark", "Mizuho", "Cowen and Company", "Loop Capital",
+}
+
+var companyWords = []string{
+	"Apex", "Blue", "Cedar", "Delta", "Evergreen", "Frontier", "Granite", "Harbor", "Iron", "Jade",
+	"Keystone", "Lumen", "Meridian", "Northern", "Orion", "Pioneer", "Quantum", "River", "Summit", "Titan",
+	"Unity", "Vertex", "Willow", "Zenith",
+}
+
+var companyIndustries = []string{
+	"Therapeutics", "Semiconductor", "Energy", "Financial", "Software", "Biosciences", "Logistics",
+	"Networks", "Pharmaceuticals", "Motors", "Holdings", "Realty", "Foods", "Robotics", "Mining",
+}
+
+var companySuffixes = []string{"Inc.", "Corp.", "Ltd.", "Group", "Co.", "plc"}
+
+// Ratings from most bearish to most bullish, upgrades move right
+var ratings = []string{
+	"Strong Sell", "Sell", "Underperform", "Underweight", "Reduce",
+	"Hold", "Neutral", "Equal Weight", "Market Perform", "Sector Perform",
+	"Outperform", "Overweight", "Moderate Buy", "Buy", "Strong-Buy",
+}
+
+var malformedPrices = []string{"N/A", "", "$", "$--", "USD 12", "12..50", "$1,2a3.00", "null"}
+
+type generatorOptions struct {
+	items         int
+	tickers       int
+	brokerages    int
+	malformedRate float64
+	seed          uint64
+}
+
+// generateItems builds the whole dataset up front from the seed, so every page
+// is the same across requests and restarts.
+func generateItems(opts generatorOptions) []clients.StockData {
+	rng := rand.New(rand.NewPCG(opts.seed, opts.seed^0x5eed))
+
+	type company struct {
+		ticker string
+		name   string
+		price  float64
+	}
+	companies := make([]company, 0, opts.tickers)
+	seen := map[string]bool{}
+	for len(companies) < opts.tickers {
+		ticker := randomTicker(rng)
+		if seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+		companies = append(companies, company{
+			ticker: ticker,
+			name: fmt.Sprintf("%s %s %s", companyWords[rng.IntN(len(companyWords))],
+				companyIndustries[rng.IntN(len(companyIndustries))], companySuffixes[rng.IntN(len(companySuffixes))]),
+			// Log-uniform between $2 and $2000 like a real listing
+			price: math.Exp(math.Log(2) + rng.Float64()*(math.Log(2000)-math.Log(2))),
+		})
+	}
+
+	firms := brokerages[:min(opts.brokerages, len(brokerages))]
+	now := time.Now().UTC().Truncate(time.Hour)
+	items := make([]clients.StockData, 0, opts.items)
+	for i := 0; i < opts.items; i++ {
+		c := companies[rng.IntN(len(companies))]
+		item := clients.StockData{
+			Ticker:    c.ticker,
+			Company:   c.name,
+			Brokerage: firms[rng.IntN(len(firms))],
+			Time:      now.Add(-time.Duration(rng.IntN(30*24*60)) * time.Minute).Format(time.RFC3339Nano),
+		}
+
+		from := rng.IntN(len(ratings))
+		to := from
+		targetFrom := c.price * (0.8 + rng.Float64()*0.6)
+		targetTo := targetFrom
+		switch rng.IntN(6) {
+		case 0:
+			item.Action = "upgraded by"
+			if from == len(ratings)-1 {
+				from--
+			}
+			to = from + 1 + rng.IntN(len(ratings)-from-1)
+			targetTo = targetFrom * (1.05 + rng.Float64()*0.3)
+		case 1:
+			item.Action = "downgraded by"
+			if from == 0 {
+				from++
+			}
+			to = rng.IntN(from)
+			targetTo = targetFrom * (0.65 + rng.Float64()*0.3)
+		case 2:
+			item.Action = "target raised by"
+			targetTo = targetFrom * (1.02 + rng.Float64()*0.25)
+		case 3:
+			item.Action = "target lowered by"
+			targetTo = targetFrom * (0.75 + rng.Float64()*0.23)
+		case 4:
+			item.Action = "initiated by"
+		default:
+			item.Action = "reiterated by"
+		}
+		item.RatingFrom = ratings[from]
+		item.RatingTo = ratings[to]
+		item.TargetFrom = formatPrice(targetFrom)
+		item.TargetTo = formatPrice(targetTo)
+
+		if rng.Float64() < opts.malformedRate {
+			if rng.IntN(2) == 0 {
+				item.TargetFrom = malformedPrices[rng.IntN(len(malformedPrices))]
+			} else {
+				item.TargetTo = malformedPrices[rng.IntN(len(malformedPrices))]
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func randomTicker(rng *rand.Rand) string {
+	var b strings.Builder
+	for n := 1 + rng.IntN(5); n > 0; n-- {
+		b.WriteByte(byte('A' + rng.IntN(26)))
+	}
+	return b.String()
+}
+
+// formatPrice renders a price like the upstream feed does, "$1,234.56".
+func formatPrice(value float64) string {
+	cents := int64(math.Round(value * 100))
+	whole := fmt.Sprintf("%d", cents/100)
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return fmt.Sprintf("$%s.%02d", b.String(), cents%100)
+}
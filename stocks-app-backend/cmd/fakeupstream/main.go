@@ -0,0 +1,257 @@
+// Command fakeupstream serves generated analyst ratings on the same
+// /production/swechallenge/list contract as the upstream API, for demos and
+// load tests without a vendor key. Point API_BASE_URL at it:
+//
+//	go run ./cmd/fakeupstream -items 5000 -api-key secret -error-rate 0.05
+//	API_BASE_URL=http://localhost:9090 API_KEY=secret go run .
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/clients"
+	"github.com/felipepalacio293/stocks-app/logging"
+)
+
+const listPath = "/production/swechallenge/list"
+
+// 5 letter tickers leave room for about 12M, keep the generator well away
+const maxTickers = 100000
+
+type server struct {
+	items         []clients.StockData
+	pageSize      int
+	apiKey        string
+	errorRate     float64
+	latency       time.Duration
+	latencyJitter time.Duration
+	limiter       *rateLimiter
+}
+
+func main() {
+	addr := flag.String("addr", ":9090", "address to listen on")
+	apiKey := flag.String("api-key", "", "bearer token clients must send, empty accepts any request")
+	items := flag.Int("items", 1000, "number of ratings to serve")
+	tickers := flag.Int("tickers", 300, "number of distinct tickers the ratings are spread over")
+	firms := flag.Int("brokerages", len(brokerages), fmt.Sprintf("number of brokerages issuing ratings, up to %d", len(brokerages)))
+	pageSize := flag.Int("page-size", 10, "ratings per page")
+	seed := flag.Uint64("seed", 1, "seed for the generated data, the same seed serves the same ratings")
+	malformedRate := flag.Float64("malformed-rate", 0, "fraction of ratings with an unparseable target price")
+	errorRate := flag.Float64("error-rate", 0, "fraction of requests answered with a 500, 502 or 503")
+	latency := flag.Duration("latency", 0, "delay added to every response")
+	latencyJitter := flag.Duration("latency-jitter", 0, "random extra delay, up to this much, added to every response")
+	rateLimit := flag.Int("rate-limit", 0, "requests allowed per -rate-window before answering 429, 0 disables")
+	rateWindow := flag.Duration("rate-window", time.Second, "window for -rate-limit")
+	logLevel := flag.String("log-level", "info", "debug, info, warn or error")
+	flag.Parse()
+
+	slog.SetDefault(logging.New(os.Stdout, "development", *logLevel))
+
+	if err := validateFlags(*items, *tickers, *firms, *pageSize, *malformedRate, *errorRate, *rateLimit, *rateWindow); err != nil {
+		fmt.Fprintf(os.Stderr, "fakeupstream: %v\n", err)
+		os.Exit(2)
+	}
+
+	s := &server{
+		items: generateItems(generatorOptions{
+			items:         *items,
+			tickers:       *tickers,
+			brokerages:    *firms,
+			malformedRate: *malformedRate,
+			seed:          *seed,
+		}),
+		pageSize:      *pageSize,
+		apiKey:        *apiKey,
+		errorRate:     *errorRate,
+		latency:       *latency,
+		latencyJitter: *latencyJitter,
+	}
+	if *rateLimit > 0 {
+		s.limiter = &rateLimiter{limit: *rateLimit, window: *rateWindow}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+listPath, s.list)
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Fake upstream listening",
+		slog.String("addr", *addr),
+		slog.Int("items", len(s.items)),
+		slog.Int("pages", (len(s.items)+s.pageSize-1)/s.pageSize),
+		slog.Bool("auth", s.apiKey != ""))
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Fake upstream failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func validateFlags(items, tickers, firms, pageSize int, malformedRate, errorRate float64, rateLimit int, rateWindow time.Duration) error {
+	switch {
+	case items < 0:
+		return fmt.Errorf("-items must not be negative")
+	case tickers < 1 || tickers > maxTickers:
+		return fmt.Errorf("-tickers must be between 1 and %d", maxTickers)
+	case firms < 1:
+		return fmt.Errorf("-brokerages must be at least 1")
+	case pageSize < 1:
+		return fmt.Errorf("-page-size must be at least 1")
+	case malformedRate < 0 || malformedRate > 1:
+		return fmt.Errorf("-malformed-rate must be between 0 and 1")
+	case errorRate < 0 || errorRate > 1:
+		return fmt.Errorf("-error-rate must be between 0 and 1")
+	case rateLimit < 0:
+		return fmt.Errorf("-rate-limit must not be negative")
+	case rateWindow <= 0:
+		return fmt.Errorf("-rate-window must be positive")
+	}
+	return nil
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status := s.serveList(w, r)
+	slog.Debug("Request served",
+		slog.String("path", r.URL.RequestURI()),
+		slog.Int("status", status),
+		slog.Duration("duration", time.Since(start)))
+}
+
+func (s *server) serveList(w http.ResponseWriter, r *http.Request) int {
+	if s.apiKey != "" && r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+		return writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+	}
+
+	if s.limiter != nil {
+		if ok, retryAfter := s.limiter.allow(time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+			return writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		}
+	}
+
+	if delay := s.delay(); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return 499
+		}
+	}
+
+	if s.errorRate > 0 && rand.Float64() < s.errorRate {
+		switch rand.IntN(3) {
+		case 0:
+			return writeError(w, http.StatusInternalServerError, "internal server error")
+		case 1:
+			// What a load balancer in front of the API answers, not JSON
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, "<html><body><h1>502 Bad Gateway</h1></body></html>")
+			return http.StatusBadGateway
+		default:
+			return writeError(w, http.StatusServiceUnavailable, "service temporarily unavailable")
+		}
+	}
+
+	offset := 0
+	if cursor := r.URL.Query().Get("next_page"); cursor != "" {
+		var err error
+		offset, err = decodeCursor(cursor)
+		if err != nil || offset > len(s.items) {
+			return writeError(w, http.StatusBadRequest, "invalid next_page")
+		}
+	}
+
+	end := min(offset+s.pageSize, len(s.items))
+	resp := clients.StockResponse{Items: s.items[offset:end]}
+	if end < len(s.items) {
+		resp.NextPage = encodeCursor(end)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+	return http.StatusOK
+}
+
+func (s *server) delay() time.Duration {
+	delay := s.latency
+	if s.latencyJitter > 0 {
+		delay += rand.N(s.latencyJitter)
+	}
+	return delay
+}
+
+// Cursors are opaque to clients, like the upstream ones
+func encodeCursor(offset int) string {
+	return "p" + strconv.FormatInt(int64(offset), 36)
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if len(cursor) < 2 || cursor[0] != 'p' {
+		return 0, fmt.Errorf("malformed cursor")
+	}
+	offset, err := strconv.ParseInt(cursor[1:], 36, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("malformed cursor")
+	}
+	return int(offset), nil
+}
+
+func writeError(w http.ResponseWriter, status int, message string) int {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+	return status
+}
+
+// rateLimiter allows limit requests per fixed window across all clients.
+type rateLimiter struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	windowStart time.Time
+	count       int
+}
+
+func (l *rateLimiter) allow(now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.windowStart) >= l.window {
+		l.windowStart = now
+		l.count = 0
+	}
+	if l.count >= l.limit {
+		return false, l.window - now.Sub(l.windowStart)
+	}
+	l.count++
+	return true, 0
+}
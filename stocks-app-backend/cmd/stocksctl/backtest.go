@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/services"
+)
+
+func runBacktest(args []string) error {
+	req := services.BacktestRequest{}
+
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	fs.StringVar(&req.StartDate, "start", "", "first rebalance date, YYYY-MM-DD (required)")
+	fs.StringVar(&req.EndDate, "end", "", "last rebalance date, YYYY-MM-DD (required)")
+	fs.IntVar(&req.RebalanceDays, "rebalance", services.DefaultBacktestRebalanceDays, "days between rebalances")
+	fs.IntVar(&req.HorizonDays, "horizon", services.DefaultBacktestHorizonDays, "forward return horizon in days")
+	fs.IntVar(&req.TopN, "top", services.DefaultBacktestTopN, "number of tickers picked per rebalance")
+	fs.StringVar(&req.Profile, "profile", services.DefaultScoringProfileName,
+		"scoring profile: "+strings.Join(services.ScoringProfileNames(), ", "))
+	asJSON := fs.Bool("json", false, "print the full result as JSON")
+	fs.Parse(args)
+
+	if err := req.Normalize(); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	cfg, db, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	backtestService := services.NewBacktestService(
+		repositories.NewStockRepository(db),
+		repositories.NewRatingEventRepository(db),
+		repositories.NewPriceRepository(db),
+		cfg.BacktestMaxDays,
+	)
+
+	result, err := backtestService.Run(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tUNIVERSE\tPICKS\tRETURN\tBENCHMARK\tHIT RATE\tTURNOVER")
+	for _, period := range result.Periods {
+		tickers := make([]string, 0, len(period.Picks))
+		for _, pick := range period.Picks {
+			tickers = append(tickers, pick.Ticker)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%.0f%%\n",
+			period.Date, period.UniverseSize, strings.Join(tickers, " "),
+			formatPercent(period.AverageReturn), formatPercent(period.BenchmarkReturn),
+			formatPercent(period.HitRate), period.Turnover*100)
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Printf("profile: %s  periods: %d  picks: %d\n", result.Request.Profile, result.EvaluatedPeriods, result.EvaluatedPicks)
+	fmt.Printf("hit rate: %.1f%%  avg return: %.2f%%  benchmark: %.2f%%  excess: %.2f%%  avg turnover: %.0f%%\n",
+		result.HitRate*100, result.AverageReturn*100, result.BenchmarkReturn*100,
+		result.ExcessReturn*100, result.AverageTurnover*100)
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+
+	return nil
+}
+
+func formatPercent(value *float64) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", *value*100)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/repositories"
+)
+
+func runDB(args []string) error {
+	if len(args) == 0 || args[0] != "stats" {
+		fmt.Fprintln(os.Stderr, "Usage: stocksctl db stats [-json]")
+		return fmt.Errorf("expected the stats action")
+	}
+
+	fs := flag.NewFlagSet("db stats", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the stats as JSON")
+	fs.Parse(args[1:])
+
+	_, db, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stats, err := repositories.NewStatsRepository(db).Collect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "stocks\t%d\t(%d tickers, %d brokerages)\n", stats.Stocks, stats.Tickers, stats.Brokerages)
+	fmt.Fprintf(w, "last stock update\t%s\n", formatTime(stats.LastStockUpdate, time.RFC3339))
+	fmt.Fprintf(w, "rating events\t%d\n", stats.RatingEvents)
+	fmt.Fprintf(w, "prices\t%d\t(%d tickers)\n", stats.Prices, stats.PriceTickers)
+	fmt.Fprintf(w, "price range\t%s .. %s\n",
+		formatTime(stats.FirstPriceDate, time.DateOnly), formatTime(stats.LastPriceDate, time.DateOnly))
+	return w.Flush()
+}
+
+func formatTime(t *time.Time, layout string) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/felipepalacio293/stocks-app/utils"
+)
+
+func runExport(args []string) error {
+	filter := repositories.StockFilter{}
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	what := fs.String("what", "stocks", "what to export: stocks or recommendations")
+	format := fs.String("format", utils.ExportFormatCSV, "output format: csv or ndjson")
+	out := fs.String("out", "", "output file, defaults to stdout")
+	topN := fs.Int("top", 0, "only export the best N recommendations, 0 exports all")
+	fs.StringVar(&filter.Ticker, "ticker", "", "only export tickers containing this text")
+	minUpside := fs.Float64("min-upside", 0, "only export stocks with at least this upside (0.1 = 10%)")
+	fs.StringVar(&filter.Sort, "sort", "", "stock order: upside or -upside")
+	fs.Parse(args)
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "min-upside" {
+			filter.MinUpside = minUpside
+		}
+	})
+
+	if *what != "stocks" && *what != "recommendations" {
+		fs.Usage()
+		return fmt.Errorf("-what must be stocks or recommendations")
+	}
+	if filter.Sort != "" && filter.Sort != repositories.SortUpsideAsc && filter.Sort != repositories.SortUpsideDesc {
+		return fmt.Errorf("-sort must be upside or -upside")
+	}
+	if *topN < 0 {
+		return fmt.Errorf("-top must not be negative")
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	writer, err := utils.NewExportWriter(*format, w)
+	if err != nil {
+		return err
+	}
+
+	cfg, db, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stockService := services.NewStockService(repositories.NewStockRepository(db), cfg)
+
+	rows := 0
+	if *what == "stocks" {
+		err = stockService.ExportStocks(ctx, filter, func(stock models.StockResponse) error {
+			rows++
+			return writer.Write(stock)
+		})
+	} else {
+		var recommendations []services.StockRecommendation
+		recommendations, err = stockService.ExportRecommendations(ctx, filter, *topN)
+		for _, rec := range recommendations {
+			if err = writer.Write(rec); err != nil {
+				break
+			}
+			rows++
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if *out != "" {
+		fmt.Fprintf(os.Stderr, "exported %d %s to %s\n", rows, *what, *out)
+	}
+	return nil
+}
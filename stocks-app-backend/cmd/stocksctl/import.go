@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/services"
+)
+
+type mappingFlag services.ColumnMapping
+
+func (m mappingFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for field, column := range m {
+		pairs = append(pairs, field+"="+column)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m mappingFlag) Set(value string) error {
+	field, column, ok := strings.Cut(value, "=")
+	if !ok || field == "" || column == "" {
+		return fmt.Errorf("mapping must be field=column, got %q", value)
+	}
+	m[field] = column
+	return nil
+}
+
+func runImport(args []string) error {
+	mapping := mappingFlag{}
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "", "path to the CSV file to import (required)")
+	delimiter := fs.String("delimiter", ",", "CSV field delimiter")
+	dryRun := fs.Bool("dry-run", false, "validate the file without writing to the database")
+	asJSON := fs.Bool("json", false, "print the full per-row report as JSON")
+	source := fs.String("source", services.ImportSourceName, "rating source the rows are stored under")
+	fs.Var(mapping, "map", "column mapping as field=column, repeatable (e.g. -map ticker=Symbol)")
+	fs.Parse(args)
+
+	if *file == "" {
+		fs.Usage()
+		return fmt.Errorf("-file is required")
+	}
+
+	runes := []rune(*delimiter)
+	if len(runes) != 1 {
+		return fmt.Errorf("-delimiter must be a single character")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, db, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	importService := services.NewStockImportService(repositories.NewStockRepository(db), nil, nil)
+	report, err := importService.ImportCSV(ctx, f, services.ImportOptions{
+		Mapping:   services.ColumnMapping(mapping),
+		Delimiter: runes[0],
+		DryRun:    *dryRun,
+		Source:    *source,
+	})
+	if report == nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(report); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	}
+
+	for _, row := range report.Rows {
+		if row.Status == services.ImportRowInvalid {
+			fmt.Printf("row %d (%s): %s\n", row.Row, row.Ticker, strings.Join(row.Errors, "; "))
+		}
+	}
+
+	fmt.Printf("rows: %d  created: %d  updated: %d  unchanged: %d  invalid: %d",
+		report.TotalRows, report.Created, report.Updated, report.Unchanged, report.Invalid)
+	if report.Failed > 0 {
+		fmt.Printf("  not stored: %d", report.Failed)
+	}
+	if report.DryRun {
+		fmt.Print("  (dry run)")
+	}
+	fmt.Println()
+
+	// The import may have stopped part way, the counts above are what was stored
+	return err
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/felipepalacio293/stocks-app/logging"
+	"gorm.io/gorm"
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []command{
+	{name: "sync", description: "Sync analyst ratings from the upstream API once", run: runSync},
+	{name: "recommend", description: "Print the top scored recommendations", run: runRecommend},
+	{name: "export", description: "Export stocks or recommendations as CSV or NDJSON", run: runExport},
+	{name: "import", description: "Import analyst ratings from a CSV file", run: runImport},
+	{name: "backtest", description: "Backtest the recommendation model against price history", run: runBacktest},
+	{name: "migrate", description: "Apply or inspect the database schema (up, down, status)", run: runMigrate},
+	{name: "db", description: "Database maintenance (stats)", run: runDB},
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "stocksctl %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "stocksctl: unknown command %q\n\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: stocksctl <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'stocksctl <command> -h' for command flags.")
+}
+
+func openDB() (*config.Config, *gorm.DB, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Keep stdout for command output
+	slog.SetDefault(logging.New(os.Stderr, cfg.Environment, cfg.LogLevel))
+
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	return cfg, db, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/services"
+)
+
+func runRecommend(args []string) error {
+	fs := flag.NewFlagSet("recommend", flag.ExitOnError)
+	topN := fs.Int("top", 10, "number of recommendations to print")
+	profile := fs.String("profile", services.DefaultScoringProfileName,
+		"scoring profile: "+strings.Join(services.ScoringProfileNames(), ", "))
+	asJSON := fs.Bool("json", false, "print the recommendations as JSON")
+	fs.Parse(args)
+
+	if *topN < 1 {
+		fs.Usage()
+		return fmt.Errorf("-top must be at least 1")
+	}
+	if _, err := services.GetScoringProfile(*profile); err != nil {
+		return err
+	}
+
+	cfg, db, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stockService := services.NewStockService(repositories.NewStockRepository(db), cfg)
+	recommendations, err := stockService.GetStockRecommendations(ctx, *topN, *profile)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(recommendations)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tTICKER\tCOMPANY\tSCORE\tRATING\tACTION\tTARGET\tUPSIDE")
+	for i, rec := range recommendations {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%.2f\t%s\t%s\t%.2f\t%s\n",
+			i+1, rec.Ticker, rec.Company, rec.Score, rec.Rating, rec.Action, rec.TargetPrice, formatPercent(rec.Upside))
+	}
+	return w.Flush()
+}
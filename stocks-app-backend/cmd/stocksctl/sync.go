@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/sources"
+	"github.com/felipepalacio293/stocks-app/tasks"
+)
+
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	sourceNames := fs.String("source", "", "comma-separated rating sources to sync, defaults to every source in RATING_SOURCES")
+	quiet := fs.Bool("quiet", false, "do not print progress while fetching")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	fs.Parse(args)
+
+	cfg, db, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	registry, err := sources.FromConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	names := registry.Names()
+	if *sourceNames != "" {
+		names = strings.Split(*sourceNames, ",")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// No event hub or cache here, running servers pick the changes up on their
+	// next cache expiry
+	syncTask := tasks.NewStockSyncTask(repositories.NewStockRepository(db), registry, nil, nil, nil)
+
+	results := make([]*tasks.SyncResult, 0, len(names))
+	var errs []error
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		source, ok := registry.Get(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("rating source %q is not configured, RATING_SOURCES has %s", name, strings.Join(registry.Names(), ", ")))
+			continue
+		}
+
+		if reporter, ok := source.(sources.ProgressReporter); ok && !*quiet {
+			fetched := 0
+			reporter.OnPage(func(page, items int) {
+				fetched += items
+				fmt.Fprintf(os.Stderr, "%s: fetched page %d (%d items, %d total)\n", name, page, items, fetched)
+			})
+		}
+
+		result, err := syncTask.SyncSource(ctx, source)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if result == nil {
+			continue
+		}
+		results = append(results, result)
+
+		if !*asJSON {
+			fmt.Printf("%s: fetched: %d  inserted: %d  updated: %d  unchanged: %d  took: %s\n",
+				result.Source, result.Fetched, result.Inserted, result.Updated, result.Unchanged, result.Duration.Round(time.Millisecond))
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -2,27 +2,154 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/felipepalacio293/stocks-app/schedule"
 	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
+// RateLimit allows Requests per Per window for each client, with bursts of
+// up to Requests.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+const (
+	DBDriverPostgres  = "postgres"
+	DBDriverCockroach = "cockroachdb"
+	DBDriverSQLite    = "sqlite"
+)
+
+const (
+	UpstreamModeLive   = "live"
+	UpstreamModeRecord = "record"
+	UpstreamModeReplay = "replay"
+)
+
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
 type Config struct {
-	ServerPort        string
-	DBHost            string
-	DBPort            string
-	DBUser            string
-	DBPassword        string
-	DBName            string
+	ServerPort string
+	// postgres, cockroachdb (same wire protocol) or sqlite, the latter stores
+	// the database in DBPath, ":memory:" keeps it in memory for the process
+	DBDriver   string
+	DBPath     string
+	DBHost     string
+	DBPort     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+	// DBURL is a full postgres:// URL or key=value DSN, it takes precedence
+	// over the individual DB_* settings above. The TLS, timeout and name
+	// settings below are only added when the URL does not set them already
+	DBURL             string
+	DBSSLMode         string
+	DBSSLRootCert     string
+	DBSSLCert         string
+	DBSSLKey          string
+	DBApplicationName string
+	// Applied by the server to every statement, 0 leaves the server default
+	DBStatementTimeout time.Duration
+
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+
+	// Attempts to connect at startup before giving up, waiting DBConnectBackoff
+	// after the first failure and doubling it after each one
+	DBConnectRetries int
+	DBConnectBackoff time.Duration
+
 	AllowedOrigins    []string
 	Environment       string
 	EnableRequestLogs bool
-	APIBaseURL        string
-	APIKey            string
+	LogLevel          string
+	// Error body format, "legacy" (utils.Response) or "problem" (RFC 7807).
+	// Clients can always ask for problem+json through the Accept header.
+	ErrorFormat string
+	// Request and response bodies are only logged outside production
+	LogBodyMaxBytes      int
+	LogSuccessSampleRate float64
+	APIBaseURL           string
+	APIKey               string
+	AdminAPIKey          string
+	PricesDir            string
+	PriceLoadInterval    time.Duration
+	// Longest start_date to end_date span a backtest may replay
+	BacktestMaxDays int
+
+	// Rating sources synced by the server: swechallenge (API_BASE_URL),
+	// vendor (VENDOR_API_URL) and csv (RATINGS_DIR drop folder)
+	RatingSources []string
+	VendorAPIURL  string
+	VendorAPIKey  string
+	RatingsDir    string
+
+	// live talks to API_BASE_URL, record also saves every page it gets into
+	// UpstreamFixturesDir and replay serves those pages without the network
+	UpstreamMode        string
+	UpstreamFixturesDir string
+
+	TracingExporter    string
+	TracingServiceName string
+	TracingSampleRatio float64
+
+	// CORS, AllowedOrigins entries may use a leading wildcard subdomain
+	// (https://*.example.com) or be "*" to allow any origin without credentials
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSExposedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	// Proxies whose X-Forwarded-For is trusted for the client IP, by default
+	// only the connection address is used
+	TrustedProxies []string
+	// Rate limits by route group (stocks, recommendations, backtests, admin),
+	// groups that are not listed are not limited
+	RateLimits map[string]RateLimit
+	// API keys that get a rate limit bucket of their own, requests with any
+	// other key or none are limited by IP
+	RateLimitAPIKeys []string
+
+	// Response cache for list and recommendation endpoints, CacheMaxAge is
+	// what clients may reuse without revalidating
+	CacheMaxEntries int
+	CacheTTL        time.Duration
+	CacheMaxAge     time.Duration
+
+	// Readiness reports degraded once a source has gone this long past the
+	// sync its schedule planned after its last success
+	SyncMaxAge time.Duration
+
+	// When each rating source syncs, SYNC_SCHEDULE for all of them and
+	// SYNC_SCHEDULES overriding it per source. The market-hours schedule uses
+	// the MARKET_* trading calendar
+	SyncSchedule    schedule.Schedule
+	SourceSchedules map[string]schedule.Schedule
+
+	// How long in-flight HTTP requests and background tasks get to finish
+	// after a shutdown signal
+	HTTPShutdownTimeout time.Duration
+	TaskShutdownTimeout time.Duration
+
+	// When false the server refuses to start with pending migrations instead
+	// of applying them, for deployments that run stocksctl migrate up first
+	MigrateOnStart       bool
+	MigrationLockTimeout time.Duration
 }
 
 func LoadConfig() (*Config, error) {
@@ -33,18 +160,261 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("invalid ENABLE_REQUEST_LOGS: %w", err)
 	}
 
+	environment := getEnv("ENVIRONMENT", "development")
+
+	defaultLogLevel := "debug"
+	if environment == "production" {
+		defaultLogLevel = "info"
+	}
+
+	dbDriver := getEnv("DB_DRIVER", DBDriverPostgres)
+	if dbDriver != DBDriverPostgres && dbDriver != DBDriverCockroach && dbDriver != DBDriverSQLite {
+		return nil, fmt.Errorf("invalid DB_DRIVER: must be postgres, cockroachdb or sqlite")
+	}
+
+	// Empty keeps sslmode from DATABASE_URL, or disable when it is not used
+	dbSSLMode := getEnv("DB_SSLMODE", "")
+	if dbSSLMode != "" && !validSSLModes[dbSSLMode] {
+		return nil, fmt.Errorf("invalid DB_SSLMODE: must be disable, allow, prefer, require, verify-ca or verify-full")
+	}
+
+	dbSSLFiles := map[string]string{
+		"DB_SSLROOTCERT": getEnv("DB_SSLROOTCERT", ""),
+		"DB_SSLCERT":     getEnv("DB_SSLCERT", ""),
+		"DB_SSLKEY":      getEnv("DB_SSLKEY", ""),
+	}
+	for key, file := range dbSSLFiles {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	dbStatementTimeout, err := time.ParseDuration(getEnv("DB_STATEMENT_TIMEOUT", "0s"))
+	if err != nil || dbStatementTimeout < 0 {
+		return nil, fmt.Errorf("invalid DB_STATEMENT_TIMEOUT: must be a non-negative duration")
+	}
+
+	dbMaxOpenConns, err := strconv.Atoi(getEnv("DB_MAX_OPEN_CONNS", "25"))
+	if err != nil || dbMaxOpenConns < 0 {
+		return nil, fmt.Errorf("invalid DB_MAX_OPEN_CONNS: must be a non-negative integer")
+	}
+
+	dbMaxIdleConns, err := strconv.Atoi(getEnv("DB_MAX_IDLE_CONNS", "10"))
+	if err != nil || dbMaxIdleConns < 0 {
+		return nil, fmt.Errorf("invalid DB_MAX_IDLE_CONNS: must be a non-negative integer")
+	}
+
+	dbConnMaxLifetime, err := time.ParseDuration(getEnv("DB_CONN_MAX_LIFETIME", "30m"))
+	if err != nil || dbConnMaxLifetime < 0 {
+		return nil, fmt.Errorf("invalid DB_CONN_MAX_LIFETIME: must be a non-negative duration")
+	}
+
+	dbConnMaxIdleTime, err := time.ParseDuration(getEnv("DB_CONN_MAX_IDLE_TIME", "5m"))
+	if err != nil || dbConnMaxIdleTime < 0 {
+		return nil, fmt.Errorf("invalid DB_CONN_MAX_IDLE_TIME: must be a non-negative duration")
+	}
+
+	dbConnectRetries, err := strconv.Atoi(getEnv("DB_CONNECT_RETRIES", "5"))
+	if err != nil || dbConnectRetries < 1 {
+		return nil, fmt.Errorf("invalid DB_CONNECT_RETRIES: must be a positive integer")
+	}
+
+	dbConnectBackoff, err := time.ParseDuration(getEnv("DB_CONNECT_BACKOFF", "1s"))
+	if err != nil || dbConnectBackoff < 0 {
+		return nil, fmt.Errorf("invalid DB_CONNECT_BACKOFF: must be a non-negative duration")
+	}
+
+	errorFormat := getEnv("ERROR_FORMAT", "legacy")
+	if errorFormat != "legacy" && errorFormat != "problem" {
+		return nil, fmt.Errorf("invalid ERROR_FORMAT: must be legacy or problem")
+	}
+
+	logBodyMaxBytes, err := strconv.Atoi(getEnv("LOG_BODY_MAX_BYTES", "4096"))
+	if err != nil || logBodyMaxBytes < 0 {
+		return nil, fmt.Errorf("invalid LOG_BODY_MAX_BYTES: must be a non-negative integer")
+	}
+
+	logSuccessSampleRate, err := strconv.ParseFloat(getEnv("LOG_SUCCESS_SAMPLE_RATE", "1"), 64)
+	if err != nil || logSuccessSampleRate < 0 || logSuccessSampleRate > 1 {
+		return nil, fmt.Errorf("invalid LOG_SUCCESS_SAMPLE_RATE: must be a number between 0 and 1")
+	}
+
+	backtestMaxDays, err := strconv.Atoi(getEnv("BACKTEST_MAX_DAYS", "1095"))
+	if err != nil || backtestMaxDays < 1 {
+		return nil, fmt.Errorf("invalid BACKTEST_MAX_DAYS: must be a positive integer")
+	}
+
+	priceLoadInterval, err := time.ParseDuration(getEnv("PRICE_LOAD_INTERVAL", "1h"))
+	if err != nil || priceLoadInterval <= 0 {
+		return nil, fmt.Errorf("invalid PRICE_LOAD_INTERVAL: must be a positive duration")
+	}
+
+	tracingSampleRatio, err := strconv.ParseFloat(getEnv("TRACING_SAMPLE_RATIO", "1"), 64)
+	if err != nil || tracingSampleRatio < 0 || tracingSampleRatio > 1 {
+		return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO: must be a number between 0 and 1")
+	}
+
+	corsAllowCredentials, err := strconv.ParseBool(getEnv("CORS_ALLOW_CREDENTIALS", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CORS_ALLOW_CREDENTIALS: %w", err)
+	}
+
+	corsMaxAge, err := time.ParseDuration(getEnv("CORS_MAX_AGE", "10m"))
+	if err != nil || corsMaxAge < 0 {
+		return nil, fmt.Errorf("invalid CORS_MAX_AGE: must be a non-negative duration")
+	}
+
+	trustedProxies := splitList(getEnv("TRUSTED_PROXIES", ""))
+	for _, proxy := range trustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %q is not an IP or CIDR", proxy)
+			}
+		}
+	}
+
+	rateLimits, err := parseRateLimits(getEnv("RATE_LIMITS", "stocks=120/1m,recommendations=30/1m,backtests=5/1m,admin=60/1m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMITS: %w", err)
+	}
+
+	cacheMaxEntries, err := strconv.Atoi(getEnv("CACHE_MAX_ENTRIES", "1000"))
+	if err != nil || cacheMaxEntries < 0 {
+		return nil, fmt.Errorf("invalid CACHE_MAX_ENTRIES: must be a non-negative integer")
+	}
+
+	cacheTTL, err := time.ParseDuration(getEnv("CACHE_TTL", "5m"))
+	if err != nil || cacheTTL <= 0 {
+		return nil, fmt.Errorf("invalid CACHE_TTL: must be a positive duration")
+	}
+
+	cacheMaxAge, err := time.ParseDuration(getEnv("CACHE_MAX_AGE", "0s"))
+	if err != nil || cacheMaxAge < 0 {
+		return nil, fmt.Errorf("invalid CACHE_MAX_AGE: must be a non-negative duration")
+	}
+
+	syncMaxAge, err := time.ParseDuration(getEnv("SYNC_MAX_AGE", "2h"))
+	if err != nil || syncMaxAge < 0 {
+		return nil, fmt.Errorf("invalid SYNC_MAX_AGE: must be a non-negative duration")
+	}
+
+	calendar, err := tradingCalendarFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	syncSchedule, err := schedule.Parse(getEnv("SYNC_SCHEDULE", "30m"), calendar)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SYNC_SCHEDULE: %w", err)
+	}
+
+	sourceSchedules, err := parseSourceSchedules(getEnv("SYNC_SCHEDULES", ""), calendar)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SYNC_SCHEDULES: %w", err)
+	}
+
+	httpShutdownTimeout, err := time.ParseDuration(getEnv("HTTP_SHUTDOWN_TIMEOUT", "15s"))
+	if err != nil || httpShutdownTimeout <= 0 {
+		return nil, fmt.Errorf("invalid HTTP_SHUTDOWN_TIMEOUT: must be a positive duration")
+	}
+
+	taskShutdownTimeout, err := time.ParseDuration(getEnv("TASK_SHUTDOWN_TIMEOUT", "30s"))
+	if err != nil || taskShutdownTimeout <= 0 {
+		return nil, fmt.Errorf("invalid TASK_SHUTDOWN_TIMEOUT: must be a positive duration")
+	}
+
+	migrateOnStart, err := strconv.ParseBool(getEnv("MIGRATE_ON_START", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MIGRATE_ON_START: %w", err)
+	}
+
+	migrationLockTimeout, err := time.ParseDuration(getEnv("MIGRATION_LOCK_TIMEOUT", "2m"))
+	if err != nil || migrationLockTimeout <= 0 {
+		return nil, fmt.Errorf("invalid MIGRATION_LOCK_TIMEOUT: must be a positive duration")
+	}
+
+	upstreamMode := getEnv("UPSTREAM_MODE", UpstreamModeLive)
+	if upstreamMode != UpstreamModeLive && upstreamMode != UpstreamModeRecord && upstreamMode != UpstreamModeReplay {
+		return nil, fmt.Errorf("invalid UPSTREAM_MODE: must be live, record or replay")
+	}
+
 	return &Config{
 		ServerPort:        getEnv("SERVER_PORT", "8080"),
+		DBDriver:          dbDriver,
+		DBPath:            getEnv("DB_PATH", "stocks.db"),
 		DBHost:            getEnv("DB_HOST", "localhost"),
 		DBPort:            getEnv("DB_PORT", "26257"),
 		DBUser:            getEnv("DB_USER", "root"),
 		DBPassword:        getEnv("DB_PASSWORD", ""),
 		DBName:            getEnv("DB_NAME", "go_api"),
-		AllowedOrigins:    []string{getEnv("ALLOWED_ORIGINS", "*")},
-		Environment:       getEnv("ENVIRONMENT", "development"),
+		DBURL:             getEnv("DATABASE_URL", ""),
+		DBSSLMode:         dbSSLMode,
+		DBSSLRootCert:     dbSSLFiles["DB_SSLROOTCERT"],
+		DBSSLCert:         dbSSLFiles["DB_SSLCERT"],
+		DBSSLKey:          dbSSLFiles["DB_SSLKEY"],
+		DBApplicationName: getEnv("DB_APPLICATION_NAME", "stocks-app-backend"),
+
+		DBStatementTimeout: dbStatementTimeout,
+		DBMaxOpenConns:     dbMaxOpenConns,
+		DBMaxIdleConns:     dbMaxIdleConns,
+		DBConnMaxLifetime:  dbConnMaxLifetime,
+		DBConnMaxIdleTime:  dbConnMaxIdleTime,
+		DBConnectRetries:   dbConnectRetries,
+		DBConnectBackoff:   dbConnectBackoff,
+
+		AllowedOrigins:    splitList(getEnv("ALLOWED_ORIGINS", "*")),
+		Environment:       environment,
 		EnableRequestLogs: enableLogs,
-		APIBaseURL:        getEnv("API_BASE_URL", ""),
-		APIKey:            getEnv("API_KEY", ""),
+		LogLevel:          getEnv("LOG_LEVEL", defaultLogLevel),
+		ErrorFormat:       errorFormat,
+
+		LogBodyMaxBytes:      logBodyMaxBytes,
+		LogSuccessSampleRate: logSuccessSampleRate,
+		APIBaseURL:           getEnv("API_BASE_URL", ""),
+		APIKey:               getEnv("API_KEY", ""),
+		AdminAPIKey:          getEnv("ADMIN_API_KEY", ""),
+		PricesDir:            getEnv("PRICES_DIR", ""),
+		PriceLoadInterval:    priceLoadInterval,
+		BacktestMaxDays:      backtestMaxDays,
+
+		RatingSources: splitList(getEnv("RATING_SOURCES", "swechallenge")),
+		VendorAPIURL:  getEnv("VENDOR_API_URL", ""),
+		VendorAPIKey:  getEnv("VENDOR_API_KEY", ""),
+		RatingsDir:    getEnv("RATINGS_DIR", ""),
+
+		UpstreamMode:        upstreamMode,
+		UpstreamFixturesDir: getEnv("UPSTREAM_FIXTURES_DIR", "testdata/upstream"),
+
+		TracingExporter:    getEnv("TRACING_EXPORTER", "none"),
+		TracingServiceName: getEnv("OTEL_SERVICE_NAME", "stocks-app-backend"),
+		TracingSampleRatio: tracingSampleRatio,
+
+		CORSAllowedMethods:   splitList(getEnv("CORS_ALLOWED_METHODS", "GET, POST, PUT, DELETE, OPTIONS")),
+		CORSAllowedHeaders:   splitList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, X-Request-ID, Last-Event-ID")),
+		CORSExposedHeaders:   splitList(getEnv("CORS_EXPOSED_HEADERS", "X-Request-ID, Content-Disposition, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After")),
+		CORSAllowCredentials: corsAllowCredentials,
+		CORSMaxAge:           corsMaxAge,
+
+		TrustedProxies:   trustedProxies,
+		RateLimits:       rateLimits,
+		RateLimitAPIKeys: splitList(getEnv("RATE_LIMIT_API_KEYS", "")),
+
+		CacheMaxEntries: cacheMaxEntries,
+		CacheTTL:        cacheTTL,
+		CacheMaxAge:     cacheMaxAge,
+
+		SyncMaxAge:      syncMaxAge,
+		SyncSchedule:    syncSchedule,
+		SourceSchedules: sourceSchedules,
+
+		HTTPShutdownTimeout: httpShutdownTimeout,
+		TaskShutdownTimeout: taskShutdownTimeout,
+
+		MigrateOnStart:       migrateOnStart,
+		MigrationLockTimeout: migrationLockTimeout,
 	}, nil
 }
 
@@ -56,20 +426,92 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
-func InitDB(cfg *Config) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+// splitList parses a comma-separated env value, dropping empty entries.
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseRateLimits parses "group=requests/window" pairs, e.g.
+// "stocks=120/1m,backtests=5/1m". A limit of 0 disables the group's limiter.
+func parseRateLimits(value string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, item := range splitList(value) {
+		group, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q must look like group=requests/window", item)
+		}
+
+		requestsStr, perStr, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("%q must look like group=requests/window", item)
+		}
+
+		requests, err := strconv.Atoi(strings.TrimSpace(requestsStr))
+		if err != nil || requests < 0 {
+			return nil, fmt.Errorf("%q: requests must be a non-negative integer", item)
+		}
+
+		per, err := time.ParseDuration(strings.TrimSpace(perStr))
+		if err != nil || per <= 0 {
+			return nil, fmt.Errorf("%q: window must be a positive duration", item)
+		}
+
+		if requests > 0 {
+			limits[strings.TrimSpace(group)] = RateLimit{Requests: requests, Per: per}
+		}
+	}
+	return limits, nil
+}
 
-	var logLevel logger.LogLevel
-	if cfg.Environment == "production" {
-		logLevel = logger.Error
-	} else {
-		logLevel = logger.Info
+func tradingCalendarFromEnv() (*schedule.TradingCalendar, error) {
+	inSession, err := time.ParseDuration(getEnv("SYNC_SESSION_INTERVAL", "5m"))
+	if err != nil || inSession <= 0 {
+		return nil, fmt.Errorf("invalid SYNC_SESSION_INTERVAL: must be a positive duration")
 	}
 
-	config := &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+	offSession, err := time.ParseDuration(getEnv("SYNC_OFF_SESSION_INTERVAL", "1h"))
+	if err != nil || offSession <= 0 {
+		return nil, fmt.Errorf("invalid SYNC_OFF_SESSION_INTERVAL: must be a positive duration")
 	}
 
-	return gorm.Open(postgres.Open(dsn), config)
+	calendar, err := schedule.NewTradingCalendar(
+		getEnv("MARKET_TIMEZONE", "America/New_York"),
+		getEnv("MARKET_HOURS", "09:30-16:00"),
+		splitList(getEnv("MARKET_HOLIDAYS", "")),
+		inSession,
+		offSession,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trading calendar: %w", err)
+	}
+	return calendar, nil
+}
+
+// parseSourceSchedules reads source=schedule pairs separated by semicolons,
+// cron expressions use commas themselves.
+func parseSourceSchedules(value string, calendar *schedule.TradingCalendar) (map[string]schedule.Schedule, error) {
+	schedules := make(map[string]schedule.Schedule)
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		source, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q must look like source=schedule", item)
+		}
+
+		parsed, err := schedule.Parse(spec, calendar)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.TrimSpace(source), err)
+		}
+		schedules[strings.TrimSpace(source)] = parsed
+	}
+	return schedules, nil
 }
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigDurationBounds(t *testing.T) {
+	cases := []struct {
+		env     string
+		value   string
+		wantErr string
+	}{
+		{"PRICE_LOAD_INTERVAL", "0s", "invalid PRICE_LOAD_INTERVAL: must be a positive duration"},
+		{"PRICE_LOAD_INTERVAL", "-1h", "invalid PRICE_LOAD_INTERVAL: must be a positive duration"},
+		{"PRICE_LOAD_INTERVAL", "15m", ""},
+		{"CACHE_TTL", "0s", "invalid CACHE_TTL: must be a positive duration"},
+		{"SYNC_MAX_AGE", "-1m", "invalid SYNC_MAX_AGE: must be a non-negative duration"},
+		// 0 turns the readiness check off
+		{"SYNC_MAX_AGE", "0s", ""},
+		{"HTTP_SHUTDOWN_TIMEOUT", "0s", "invalid HTTP_SHUTDOWN_TIMEOUT: must be a positive duration"},
+		{"TASK_SHUTDOWN_TIMEOUT", "-5s", "invalid TASK_SHUTDOWN_TIMEOUT: must be a positive duration"},
+		{"MIGRATION_LOCK_TIMEOUT", "0s", "invalid MIGRATION_LOCK_TIMEOUT: must be a positive duration"},
+		{"SYNC_SESSION_INTERVAL", "0s", "invalid SYNC_SESSION_INTERVAL: must be a positive duration"},
+		{"SYNC_OFF_SESSION_INTERVAL", "soon", "invalid SYNC_OFF_SESSION_INTERVAL: must be a positive duration"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.env+"="+tc.value, func(t *testing.T) {
+			t.Setenv(tc.env, tc.value)
+
+			_, err := LoadConfig()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadConfig: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("LoadConfig error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
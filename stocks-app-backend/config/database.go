@@ -0,0 +1,188 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/logging"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const maxConnectBackoff = 30 * time.Second
+
+// InitDB opens the configured database, retrying with exponential backoff so
+// the server can start before the database is reachable (e.g. in compose).
+func InitDB(cfg *Config) (*gorm.DB, error) {
+	var logLevel logger.LogLevel
+	if cfg.Environment == "production" {
+		logLevel = logger.Error
+	} else {
+		logLevel = logger.Info
+	}
+
+	config := &gorm.Config{
+		Logger: logging.NewGormLogger(logLevel, 200*time.Millisecond),
+	}
+
+	attempts := cfg.DBConnectRetries
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := cfg.DBConnectBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var db *gorm.DB
+		db, err = openDB(cfg, config)
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= attempts {
+			break
+		}
+
+		slog.Warn("Failed to connect to the database, retrying",
+			slog.Int("attempt", attempt),
+			slog.Int("max_attempts", attempts),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err))
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+}
+
+func openDB(cfg *Config, config *gorm.Config) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	if cfg.DBDriver == DBDriverSQLite {
+		dialector = sqlite.Open(sqliteDSN(cfg.DBPath))
+	} else {
+		dialector = postgres.Open(postgresDSN(cfg))
+	}
+
+	// Open pings the database, so an unreachable server fails here
+	db, err := gorm.Open(dialector, config)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	configurePool(sqlDB, cfg)
+
+	return db, nil
+}
+
+func configurePool(sqlDB *sql.DB, cfg *Config) {
+	// Every connection to an in-memory SQLite database gets its own empty
+	// database, so the pool is kept to the one connection that holds the data
+	if cfg.DBDriver == DBDriverSQLite && cfg.DBPath == ":memory:" {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+		return
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+}
+
+func sqliteDSN(path string) string {
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	return dsn
+}
+
+// postgresDSN builds the connection string for postgres and CockroachDB. The
+// TLS, application name and statement timeout settings are added to
+// DATABASE_URL only when it does not set them itself.
+func postgresDSN(cfg *Config) string {
+	params := [][2]string{
+		{"sslmode", cfg.DBSSLMode},
+		{"sslrootcert", cfg.DBSSLRootCert},
+		{"sslcert", cfg.DBSSLCert},
+		{"sslkey", cfg.DBSSLKey},
+		{"application_name", cfg.DBApplicationName},
+	}
+	if cfg.DBStatementTimeout > 0 {
+		// Understood by both postgres and CockroachDB at connection time
+		params = append(params, [2]string{"options", fmt.Sprintf("-c statement_timeout=%d", cfg.DBStatementTimeout.Milliseconds())})
+	}
+
+	if cfg.DBURL == "" {
+		if cfg.DBSSLMode == "" {
+			params[0][1] = "disable"
+		}
+		dsn := []string{
+			"host=" + quoteDSNValue(cfg.DBHost),
+			"port=" + quoteDSNValue(cfg.DBPort),
+			"user=" + quoteDSNValue(cfg.DBUser),
+			"password=" + quoteDSNValue(cfg.DBPassword),
+			"dbname=" + quoteDSNValue(cfg.DBName),
+		}
+		for _, param := range params {
+			if param[1] != "" {
+				dsn = append(dsn, param[0]+"="+quoteDSNValue(param[1]))
+			}
+		}
+		return strings.Join(dsn, " ")
+	}
+
+	if strings.HasPrefix(cfg.DBURL, "postgres://") || strings.HasPrefix(cfg.DBURL, "postgresql://") {
+		u, err := url.Parse(cfg.DBURL)
+		if err != nil {
+			// Left to the driver, which reports a better error than we could
+			return cfg.DBURL
+		}
+		query := u.Query()
+		for _, param := range params {
+			if param[1] != "" && !query.Has(param[0]) {
+				query.Set(param[0], param[1])
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	dsn := cfg.DBURL
+	for _, param := range params {
+		if param[1] != "" && !dsnHasKey(dsn, param[0]) {
+			dsn += " " + param[0] + "=" + quoteDSNValue(param[1])
+		}
+	}
+	return dsn
+}
+
+// quoteDSNValue quotes values for the key=value format, where spaces, quotes
+// and backslashes would otherwise break parsing.
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func dsnHasKey(dsn, key string) bool {
+	return regexp.MustCompile(`(^|\s)` + regexp.QuoteMeta(key) + `\s*=`).MatchString(dsn)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const maxImportFileSize = 32 << 20
+
+type AdminController struct {
+	importService *services.StockImportService
+}
+
+func NewAdminController(importService *services.StockImportService) *AdminController {
+	return &AdminController{
+		importService: importService,
+	}
+}
+
+func (c *AdminController) ImportStocks(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxImportFileSize)
+
+	var form ImportForm
+	if err := bindForm(ctx, &form); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	opts := services.ImportOptions{DryRun: form.DryRun, Source: form.Source}
+
+	if form.Mapping != "" {
+		if err := json.Unmarshal([]byte(form.Mapping), &opts.Mapping); err != nil {
+			ctx.Error(apperrors.InvalidField("mapping", "mapping must be a JSON object of field to column name"))
+			return
+		}
+	}
+
+	if form.Delimiter != "" {
+		opts.Delimiter = []rune(form.Delimiter)[0]
+	}
+
+	file, err := form.File.Open()
+	if err != nil {
+		ctx.Error(apperrors.Validation("error opening uploaded file: " + err.Error()))
+		return
+	}
+	defer file.Close()
+
+	report, err := c.importService.ImportCSV(ctx.Request.Context(), file, opts)
+	if err != nil {
+		if report != nil {
+			// Part of the file may be stored, the report says which rows
+			err = apperrors.WithData(err, report)
+		}
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.SuccessResponse(report, "Stocks imported successfully"))
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type BacktestController struct {
+	backtestService *services.BacktestService
+}
+
+func NewBacktestController(backtestService *services.BacktestService) *BacktestController {
+	return &BacktestController{
+		backtestService: backtestService,
+	}
+}
+
+func (c *BacktestController) RunBacktest(ctx *gin.Context) {
+	var req services.BacktestRequest
+	if err := bindJSON(ctx, &req); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	result, err := c.backtestService.Run(ctx.Request.Context(), req)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.SuccessResponse(result, "Backtest completed successfully"))
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/felipepalacio293/stocks-app/docs"
+	"github.com/gin-gonic/gin"
+)
+
+type DocsController struct{}
+
+func NewDocsController() *DocsController {
+	return &DocsController{}
+}
+
+func (c *DocsController) OpenAPI(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "application/json", docs.OpenAPI)
+}
+
+func (c *DocsController) UI(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", docs.IndexHTML)
+}
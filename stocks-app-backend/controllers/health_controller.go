@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/felipepalacio293/stocks-app/buildinfo"
+	"github.com/felipepalacio293/stocks-app/health"
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	checker *health.Checker
+}
+
+func NewHealthController(checker *health.Checker) *HealthController {
+	return &HealthController{
+		checker: checker,
+	}
+}
+
+// Livez only tells whether the process is up and serving, dependencies are
+// left to Readyz so a database outage doesn't get every instance restarted.
+func (c *HealthController) Livez(ctx *gin.Context) {
+	info := buildinfo.Get()
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":  health.StatusOK,
+		"version": info.Version,
+		"build":   info,
+	})
+}
+
+func (c *HealthController) Readyz(ctx *gin.Context) {
+	report := c.checker.Run(ctx.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == health.StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(status, gin.H{
+		"status":  report.Status,
+		"version": buildinfo.Version,
+		"checks":  report.Checks,
+	})
+}
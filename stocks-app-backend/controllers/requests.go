@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"mime/multipart"
+
+	"github.com/felipepalacio293/stocks-app/repositories"
+)
+
+// Query, path and form parameters of every endpoint. Invalid values are
+// rejected with a 400 listing each offending field, see bindQuery.
+
+type StockFilterQuery struct {
+	Ticker    string   `form:"ticker" binding:"max=20"`
+	MinUpside *float64 `form:"min_upside"`
+	Sort      string   `form:"sort" binding:"omitempty,oneof=upside -upside"`
+}
+
+func (q StockFilterQuery) Filter() repositories.StockFilter {
+	return repositories.StockFilter{
+		Ticker:    q.Ticker,
+		MinUpside: q.MinUpside,
+		Sort:      q.Sort,
+	}
+}
+
+type ListStocksQuery struct {
+	StockFilterQuery
+	Page     int `form:"page,default=1" binding:"min=1"`
+	PageSize int `form:"page_size,default=10" binding:"min=1,max=100"`
+}
+
+type RecommendationsQuery struct {
+	TopN    int    `form:"top_n,default=5" binding:"min=1,max=1000"`
+	Profile string `form:"profile,default=default" binding:"scoring_profile"`
+}
+
+type TopRecommendationsQuery struct {
+	Limit int `form:"limit,default=3" binding:"min=1,max=1000"`
+}
+
+type ActionURI struct {
+	Action string `uri:"action" binding:"required,action"`
+}
+
+type BrokerageURI struct {
+	Brokerage string `uri:"brokerage" binding:"required,max=100,brokerage"`
+}
+
+type RatingURI struct {
+	Rating string `uri:"rating" binding:"required,rating"`
+}
+
+type ExportStocksQuery struct {
+	StockFilterQuery
+	Format string `form:"format,default=csv" binding:"oneof=csv ndjson"`
+}
+
+type ExportRecommendationsQuery struct {
+	ExportStocksQuery
+	TopN int `form:"top_n,default=0" binding:"min=0,max=100000"`
+}
+
+type StreamQuery struct {
+	Ticker      string  `form:"ticker" binding:"max=20"`
+	Brokerage   string  `form:"brokerage" binding:"omitempty,max=100,brokerage"`
+	Action      string  `form:"action" binding:"omitempty,action"`
+	LastEventID *uint64 `form:"last_event_id"`
+}
+
+type ImportForm struct {
+	File      *multipart.FileHeader `form:"file" binding:"required"`
+	Mapping   string                `form:"mapping"`
+	Delimiter string                `form:"delimiter" binding:"omitempty,len=1"`
+	DryRun    bool                  `form:"dry_run"`
+	Source    string                `form:"source" binding:"omitempty,max=50"`
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"github.com/felipepalacio293/stocks-app/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+var tracer = tracing.Tracer("github.com/felipepalacio293/stocks-app/controllers")
+
+// respondJSON writes the body inside its own span so serialization shows up
+// separately from the service work in traces.
+func respondJSON(ctx *gin.Context, status int, body interface{}) {
+	_, span := tracer.Start(ctx.Request.Context(), "serialize")
+	defer span.End()
+
+	ctx.JSON(status, body)
+}
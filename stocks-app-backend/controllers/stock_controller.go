@@ -2,7 +2,6 @@ package controllers
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/felipepalacio293/stocks-app/services"
 	"github.com/felipepalacio293/stocks-app/utils"
@@ -20,104 +19,116 @@ func NewStockController(stockService *services.StockService) *StockController {
 }
 
 func (c *StockController) ListStocks(ctx *gin.Context) {
-	pageStr := ctx.DefaultQuery("page", "1")
-	pageSizeStr := ctx.DefaultQuery("page_size", "10")
-	ticker := ctx.DefaultQuery("ticker", "")
-
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 || pageSize > 100 {
-		pageSize = 10
+	var query ListStocksQuery
+	if err := bindQuery(ctx, &query); err != nil {
+		ctx.Error(err)
+		return
 	}
 
-	stocks, count, err := c.stockService.ListStocks(page, pageSize, ticker)
+	stocks, count, err := c.stockService.ListStocks(ctx.Request.Context(), query.Page, query.PageSize, query.Filter())
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, utils.PaginatedResponse(stocks, page, pageSize, count, "Stocks retrieved successfully"))
+	respondJSON(ctx, http.StatusOK, utils.PaginatedResponse(stocks, query.Page, query.PageSize, count, "Stocks retrieved successfully"))
 }
 
 func (c *StockController) GetStockRecommendations(ctx *gin.Context) {
-	topNStr := ctx.DefaultQuery("top_n", "5")
-
-	topN, err := strconv.Atoi(topNStr)
-	if err != nil || topN < 1 {
-		topN = 5
+	var query RecommendationsQuery
+	if err := bindQuery(ctx, &query); err != nil {
+		ctx.Error(err)
+		return
 	}
 
-	stockRecommendations, err := c.stockService.GetStockRecommendations(topN)
+	stockRecommendations, err := c.stockService.GetStockRecommendations(ctx.Request.Context(), query.TopN, query.Profile)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, utils.SuccessResponse(stockRecommendations, "Stock recommendations retrieved successfully"))
+	respondJSON(ctx, http.StatusOK, utils.SuccessResponse(stockRecommendations, "Stock recommendations retrieved successfully"))
 }
 
 func (c *StockController) GetRecommendationsByAction(ctx *gin.Context) {
-	action := ctx.Param("action")
-	topNStr := ctx.DefaultQuery("limit", "3")
-
-	topN, err := strconv.Atoi(topNStr)
-	if err != nil || topN <= 0 {
-		topN = 3
+	var uri ActionURI
+	var query TopRecommendationsQuery
+	if err := bindURI(ctx, &uri); err != nil {
+		ctx.Error(err)
+		return
+	}
+	if err := bindQuery(ctx, &query); err != nil {
+		ctx.Error(err)
+		return
 	}
 
-	stocks, err := c.stockService.GetAllStocks()
+	stocks, err := c.stockService.GetAllStocks(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
+		ctx.Error(err)
 		return
 	}
 
-	recommendations := c.stockService.GetTopStocksByAction(stocks, action, topN)
+	recommendations, err := c.stockService.GetTopStocksByAction(ctx.Request.Context(), stocks, uri.Action, query.Limit)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
-	ctx.JSON(http.StatusOK, utils.SuccessResponse(recommendations, "Stock recommendations by action retrieved successfully"))
+	respondJSON(ctx, http.StatusOK, utils.SuccessResponse(recommendations, "Stock recommendations by action retrieved successfully"))
 }
 
 func (c *StockController) GetRecommendationsByBrokerage(ctx *gin.Context) {
-	brokerage := ctx.Param("brokerage")
-	topNStr := ctx.DefaultQuery("limit", "3")
-
-	topN, err := strconv.Atoi(topNStr)
-	if err != nil || topN <= 0 {
-		topN = 3
+	var uri BrokerageURI
+	var query TopRecommendationsQuery
+	if err := bindURI(ctx, &uri); err != nil {
+		ctx.Error(err)
+		return
+	}
+	if err := bindQuery(ctx, &query); err != nil {
+		ctx.Error(err)
+		return
 	}
 
-	stocks, err := c.stockService.GetAllStocks()
+	stocks, err := c.stockService.GetAllStocks(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
+		ctx.Error(err)
 		return
 	}
 
-	recommendations := c.stockService.GetTopStocksByBrokerage(stocks, brokerage, topN)
+	recommendations, err := c.stockService.GetTopStocksByBrokerage(ctx.Request.Context(), stocks, uri.Brokerage, query.Limit)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
-	ctx.JSON(http.StatusOK, utils.SuccessResponse(recommendations, "Stock recommendations by brokerage retrieved successfully"))
+	respondJSON(ctx, http.StatusOK, utils.SuccessResponse(recommendations, "Stock recommendations by brokerage retrieved successfully"))
 }
 
 func (c *StockController) GetRecommendationsByRating(ctx *gin.Context) {
-	rating := ctx.Param("rating")
-	topNStr := ctx.DefaultQuery("limit", "3")
-
-	topN, err := strconv.Atoi(topNStr)
-	if err != nil || topN <= 0 {
-		topN = 3
+	var uri RatingURI
+	var query TopRecommendationsQuery
+	if err := bindURI(ctx, &uri); err != nil {
+		ctx.Error(err)
+		return
+	}
+	if err := bindQuery(ctx, &query); err != nil {
+		ctx.Error(err)
+		return
 	}
 
-	stocks, err := c.stockService.GetAllStocks()
+	stocks, err := c.stockService.GetAllStocks(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.ErrorResponse(err.Error()))
+		ctx.Error(err)
 		return
 	}
 
-	recommendations := c.stockService.GetTopStocksByRating(stocks, rating, topN)
+	recommendations, err := c.stockService.GetTopStocksByRating(ctx.Request.Context(), stocks, uri.Rating, query.Limit)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
-	ctx.JSON(http.StatusOK, utils.SuccessResponse(recommendations, "Stock recommendations by rating retrieved successfully"))
+	respondJSON(ctx, http.StatusOK, utils.SuccessResponse(recommendations, "Stock recommendations by rating retrieved successfully"))
 }
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func (c *StockController) ExportStocks(ctx *gin.Context) {
+	var query ExportStocksQuery
+	if err := bindQuery(ctx, &query); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	writer, err := utils.NewExportWriter(query.Format, ctx.Writer)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	setExportHeaders(ctx, "stocks", query.Format)
+
+	err = c.stockService.ExportStocks(ctx.Request.Context(), query.Filter(), func(stock models.StockResponse) error {
+		return writer.Write(stock)
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	finishExport(ctx, writer, err)
+}
+
+func (c *StockController) ExportRecommendations(ctx *gin.Context) {
+	var query ExportRecommendationsQuery
+	if err := bindQuery(ctx, &query); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	writer, err := utils.NewExportWriter(query.Format, ctx.Writer)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	recommendations, err := c.stockService.ExportRecommendations(ctx.Request.Context(), query.Filter(), query.TopN)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	setExportHeaders(ctx, "recommendations", query.Format)
+
+	for _, recommendation := range recommendations {
+		if err = writer.Write(recommendation); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	finishExport(ctx, writer, err)
+}
+
+func setExportHeaders(ctx *gin.Context, name, format string) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102-150405"), utils.ExportFileExtension(format))
+
+	ctx.Header("Content-Type", utils.ExportContentType(format))
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Header("Cache-Control", "no-store")
+	// Sent after the body, a missing or failed status means the export is
+	// incomplete even though it started with a 200
+	ctx.Header("Trailer", exportStatusTrailer+", "+exportErrorTrailer)
+}
+
+const (
+	exportStatusTrailer = "X-Export-Status"
+	exportErrorTrailer  = "X-Export-Error"
+)
+
+// finishExport reports a failure as JSON if nothing has been streamed yet.
+// Otherwise the partial body is already on the wire, so it is marked through
+// the trailers and, for NDJSON, a last error line.
+func finishExport(ctx *gin.Context, writer utils.ExportWriter, err error) {
+	if err == nil {
+		ctx.Writer.Header().Set(exportStatusTrailer, "complete")
+		return
+	}
+
+	if !ctx.Writer.Written() {
+		// The error is rendered as JSON, which keeps a Content-Type already set
+		header := ctx.Writer.Header()
+		for _, name := range []string{"Content-Type", "Content-Disposition", "Cache-Control", "Trailer"} {
+			header.Del(name)
+		}
+		ctx.Error(err)
+		return
+	}
+
+	slog.ErrorContext(ctx.Request.Context(), "Error streaming export", slog.String("path", ctx.Request.URL.Path), slog.Any("error", err))
+
+	message := "export interrupted, the output is incomplete"
+	if requestID := utils.RequestIDFromContext(ctx.Request.Context()); requestID != "" {
+		message += " (request " + requestID + ")"
+	}
+	if err := writer.WriteError(message); err == nil {
+		writer.Flush()
+	}
+	ctx.Writer.Header().Set(exportStatusTrailer, "error")
+	ctx.Writer.Header().Set(exportErrorTrailer, message)
+	ctx.Abort()
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/felipepalacio293/stocks-app/config"
+	middlewares "github.com/felipepalacio293/stocks-app/middleware"
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFinishExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name        string
+		format      string
+		rows        int
+		err         error
+		wantStatus  int
+		wantType    string
+		wantBody    string
+		wantTrailer string
+	}{
+		{"complete", utils.ExportFormatNDJSON, 1, nil, http.StatusOK, "application/x-ndjson", `"ticker":"AAPL"`, "complete"},
+		{"fails before any row", utils.ExportFormatCSV, 0, errors.New("database is down"), http.StatusInternalServerError, "application/json; charset=utf-8", `"success":false`, ""},
+		{"fails part way", utils.ExportFormatNDJSON, 1, errors.New("database is down"), http.StatusOK, "application/x-ndjson", `{"error":"export interrupted`, "error"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(middlewares.ErrorMiddleware(&config.Config{}))
+			r.GET("/export", func(ctx *gin.Context) {
+				writer, err := utils.NewExportWriter(tc.format, ctx.Writer)
+				if err != nil {
+					t.Fatalf("NewExportWriter: %v", err)
+				}
+				setExportHeaders(ctx, "stocks", tc.format)
+				for i := 0; i < tc.rows; i++ {
+					writer.Write(models.StockResponse{Ticker: "AAPL"})
+					writer.Flush()
+				}
+				finishExport(ctx, writer, tc.err)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tc.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != tc.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tc.wantType)
+			}
+			if !strings.Contains(w.Body.String(), tc.wantBody) {
+				t.Errorf("body %q does not contain %q", w.Body.String(), tc.wantBody)
+			}
+			if got := w.Header().Get(exportStatusTrailer); got != tc.wantTrailer {
+				t.Errorf("%s = %q, want %q", exportStatusTrailer, got, tc.wantTrailer)
+			}
+			if tc.err != nil && tc.rows == 0 {
+				for _, name := range []string{"Content-Disposition", "Cache-Control", "Trailer"} {
+					if got := w.Header().Get(name); got != "" {
+						t.Errorf("%s = %q on a JSON error", name, got)
+					}
+				}
+			}
+		})
+	}
+}
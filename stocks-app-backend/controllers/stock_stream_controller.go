@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/gin-gonic/gin"
+)
+
+const streamKeepAliveInterval = 15 * time.Second
+
+type StockStreamController struct {
+	eventHub *services.StockEventHub
+}
+
+func NewStockStreamController(eventHub *services.StockEventHub) *StockStreamController {
+	return &StockStreamController{
+		eventHub: eventHub,
+	}
+}
+
+func (c *StockStreamController) StreamStocks(ctx *gin.Context) {
+	var query StreamQuery
+	if err := bindQuery(ctx, &query); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	filter := services.StockEventFilter{
+		Ticker:    query.Ticker,
+		Brokerage: query.Brokerage,
+		Action:    query.Action,
+	}
+
+	// Browsers send Last-Event-ID when reconnecting, the query parameter lets
+	// clients resume a fresh connection
+	var lastEventID uint64
+	if header := ctx.GetHeader("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			ctx.Error(apperrors.InvalidField("Last-Event-ID", "Last-Event-ID must be a non-negative integer"))
+			return
+		}
+		lastEventID = id
+	} else if query.LastEventID != nil {
+		lastEventID = *query.LastEventID
+	}
+
+	sub, backlog, gap := c.eventHub.Subscribe(filter, lastEventID)
+	defer sub.Close()
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	fmt.Fprint(ctx.Writer, "retry: 5000\n\n")
+	if gap != nil {
+		if err := writeGapEvent(ctx, *gap); err != nil {
+			return
+		}
+	}
+	for _, event := range backlog {
+		if err := writeStockEvent(ctx, event); err != nil {
+			return
+		}
+	}
+	ctx.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if err := writeStockEvent(ctx, event); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(ctx.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+func writeStockEvent(ctx *gin.Context, event services.StockEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(ctx.Writer, "id: %d\nevent: stock.%s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// writeGapEvent tells the client it missed events, its id moves the client's
+// Last-Event-ID past the ones that are gone.
+func writeGapEvent(ctx *gin.Context, gap services.StockEventGap) error {
+	data, err := json.Marshal(gap)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(ctx.Writer, "id: %d\nevent: stock.reset\ndata: %s\n\n", gap.ResumeID, data)
+	return err
+}
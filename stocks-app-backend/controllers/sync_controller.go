@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/felipepalacio293/stocks-app/tasks"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type SyncController struct {
+	syncTask *tasks.StockSyncTask
+}
+
+func NewSyncController(syncTask *tasks.StockSyncTask) *SyncController {
+	return &SyncController{
+		syncTask: syncTask,
+	}
+}
+
+// Status lists the sync state of every configured rating source.
+func (c *SyncController) Status(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, utils.SuccessResponse(c.syncTask.Status(), "Sync status retrieved successfully"))
+}
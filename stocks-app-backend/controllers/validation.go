@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	// Report fields by the name clients send, not the Go field name
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"form", "uri", "json"} {
+			if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	v.RegisterValidation("rating", func(fl validator.FieldLevel) bool {
+		_, ok := services.RatingScores[fl.Field().String()]
+		return ok
+	})
+	v.RegisterValidation("action", func(fl validator.FieldLevel) bool {
+		return slices.Contains(services.KnownActions, fl.Field().String())
+	})
+	v.RegisterValidation("brokerage", func(fl validator.FieldLevel) bool {
+		return services.KnownBrokerages.Contains(fl.Field().String())
+	})
+	v.RegisterValidation("scoring_profile", func(fl validator.FieldLevel) bool {
+		_, err := services.GetScoringProfile(fl.Field().String())
+		return err == nil
+	})
+}
+
+// bindQuery binds and validates query parameters. Values that don't parse
+// are reported and left out of binding, so the rest are still validated and
+// every problem comes back in one response.
+func bindQuery(ctx *gin.Context, obj interface{}) error {
+	values := ctx.Request.URL.Query()
+
+	fields := typeErrors(reflect.TypeOf(obj), values)
+	for _, field := range fields {
+		values.Del(field.Field)
+	}
+
+	if err := binding.MapFormWithTag(obj, values, "form"); err != nil {
+		return apperrors.Validation("invalid request: " + err.Error())
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		var validationErrs validator.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return apperrors.Validation("invalid request: " + err.Error())
+		}
+		fields = append(fields, validationFieldErrors(validationErrs)...)
+	}
+
+	if len(fields) > 0 {
+		return apperrors.Validation(joinFieldMessages(fields), fields...)
+	}
+	return nil
+}
+
+func bindURI(ctx *gin.Context, obj interface{}) error {
+	if err := ctx.ShouldBindUri(obj); err != nil {
+		return bindingError(err, obj, nil)
+	}
+	return nil
+}
+
+func bindForm(ctx *gin.Context, obj interface{}) error {
+	if err := ctx.ShouldBindWith(obj, binding.FormMultipart); err != nil {
+		var values url.Values
+		if ctx.Request.MultipartForm != nil {
+			values = ctx.Request.MultipartForm.Value
+		}
+		return bindingError(err, obj, values)
+	}
+	return nil
+}
+
+func bindJSON(ctx *gin.Context, obj interface{}) error {
+	if err := ctx.ShouldBindJSON(obj); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return apperrors.InvalidField(typeErr.Field, fmt.Sprintf("%s must be %s", typeErr.Field, kindDescription(typeErr.Type.Kind())))
+		}
+		return bindingError(err, obj, nil)
+	}
+	return nil
+}
+
+// bindingError turns a gin binding error into a validation error with one
+// entry per invalid field.
+func bindingError(err error, obj interface{}, values url.Values) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := validationFieldErrors(validationErrs)
+		return apperrors.Validation(joinFieldMessages(fields), fields...)
+	}
+
+	// Values that don't parse into the field type fail before validation and
+	// gin doesn't say which field they belong to
+	if fields := typeErrors(reflect.TypeOf(obj), values); len(fields) > 0 {
+		return apperrors.Validation(joinFieldMessages(fields), fields...)
+	}
+
+	return apperrors.Validation("invalid request: " + err.Error())
+}
+
+func validationFieldErrors(validationErrs validator.ValidationErrors) []apperrors.FieldError {
+	fields := make([]apperrors.FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fields = append(fields, apperrors.FieldError{Field: fe.Field(), Message: fieldMessage(fe)})
+	}
+	return fields
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	field := fe.Field()
+	isString := fe.Kind() == reflect.String
+
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "min":
+		if isString {
+			return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if isString {
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "len":
+		return fmt.Sprintf("%s must be exactly %s characters", field, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(strings.Fields(fe.Param()), ", "))
+	case "rating":
+		return fmt.Sprintf("unknown %s %q, expected one of: %s", field, fe.Value(), strings.Join(ratingNames(), ", "))
+	case "action":
+		return fmt.Sprintf("unknown %s %q, expected one of: %s", field, fe.Value(), strings.Join(services.KnownActions, ", "))
+	case "brokerage":
+		return fmt.Sprintf("unknown %s %q, no ratings are stored for it", field, fe.Value())
+	case "scoring_profile":
+		return fmt.Sprintf("unknown scoring profile %q, expected one of: %s", fe.Value(), strings.Join(services.ScoringProfileNames(), ", "))
+	default:
+		return fmt.Sprintf("%s is invalid", field)
+	}
+}
+
+func typeErrors(t reflect.Type, values url.Values) []apperrors.FieldError {
+	fields := make([]apperrors.FieldError, 0)
+	if values == nil {
+		return fields
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, typeErrors(field.Type, values)...)
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		value := values.Get(name)
+		if name == "" || value == "" {
+			continue
+		}
+
+		kind := field.Type.Kind()
+		if kind == reflect.Ptr {
+			kind = field.Type.Elem().Kind()
+		}
+
+		var err error
+		switch kind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			_, err = strconv.ParseInt(value, 10, 64)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			_, err = strconv.ParseUint(value, 10, 64)
+		case reflect.Float32, reflect.Float64:
+			_, err = strconv.ParseFloat(value, 64)
+		case reflect.Bool:
+			_, err = strconv.ParseBool(value)
+		}
+
+		if err != nil {
+			fields = append(fields, apperrors.FieldError{Field: name, Message: fmt.Sprintf("%s must be %s", name, kindDescription(kind))})
+		}
+	}
+
+	return fields
+}
+
+func kindDescription(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	default:
+		return "a valid " + kind.String()
+	}
+}
+
+func joinFieldMessages(fields []apperrors.FieldError) string {
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func ratingNames() []string {
+	names := make([]string, 0, len(services.RatingScores))
+	for name := range services.RatingScores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/felipepalacio293/stocks-app/config"
+	middlewares "github.com/felipepalacio293/stocks-app/middleware"
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestActionAndBrokerageValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	services.KnownBrokerages.Add("Barclays", "HC Wainwright")
+
+	r := gin.New()
+	r.Use(middlewares.ErrorMiddleware(&config.Config{}))
+	r.GET("/action/:action", func(ctx *gin.Context) {
+		var uri ActionURI
+		if err := bindURI(ctx, &uri); err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+	r.GET("/brokerage/:brokerage", func(ctx *gin.Context) {
+		var uri BrokerageURI
+		if err := bindURI(ctx, &uri); err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+	r.GET("/stream", func(ctx *gin.Context) {
+		var query StreamQuery
+		if err := bindQuery(ctx, &query); err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+
+	cases := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"scored action", "/action/" + url.PathEscape("upgraded by"), http.StatusOK, ""},
+		{"unscored action", "/action/" + url.PathEscape("initiated by"), http.StatusOK, ""},
+		{"unknown action", "/action/" + url.PathEscape("bought by"), http.StatusBadRequest, `unknown action \"bought by\"`},
+		{"known brokerage", "/brokerage/" + url.PathEscape("HC Wainwright"), http.StatusOK, ""},
+		{"unknown brokerage", "/brokerage/" + url.PathEscape("Acme Securities"), http.StatusBadRequest, `unknown brokerage \"Acme Securities\"`},
+		{"stream without filters", "/stream", http.StatusOK, ""},
+		{"stream filters", "/stream?action=initiated+by&brokerage=barclays", http.StatusOK, ""},
+		{"stream unknown action", "/stream?action=bought+by", http.StatusBadRequest, `unknown action \"bought by\"`},
+		{"stream unknown brokerage", "/stream?brokerage=Acme+Securities", http.StatusBadRequest, `unknown brokerage \"Acme Securities\"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tc.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", w.Body.String(), tc.wantBody)
+			}
+		})
+	}
+}
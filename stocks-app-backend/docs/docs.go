@@ -0,0 +1,46 @@
+package docs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPI is the API description served at /openapi.json. Keep it in sync
+// with routes.go, SetupRouter logs every route missing from it.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+//go:embed index.html
+var IndexHTML []byte
+
+var pathParamPattern = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// UndocumentedRoutes returns the registered routes that have no operation in
+// the OpenAPI document, as "METHOD /path".
+func UndocumentedRoutes(routes gin.RoutesInfo) ([]string, error) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(OpenAPI, &spec); err != nil {
+		return nil, fmt.Errorf("error parsing openapi.json: %w", err)
+	}
+
+	missing := make([]string, 0)
+	for _, route := range routes {
+		// gin's /stocks/:id is /stocks/{id} in OpenAPI
+		path := pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		if _, ok := spec.Paths[path][strings.ToLower(route.Method)]; !ok {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+
+	sort.Strings(missing)
+	return missing, nil
+}
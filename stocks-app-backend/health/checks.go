@@ -0,0 +1,33 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/migrations"
+)
+
+func DBPing(sqlDB *sql.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// Migrations fails while the schema does not match the migrations built into
+// this binary, e.g. when another release rolled it back or forward.
+func Migrations(migrator *migrations.Migrator) CheckFunc {
+	return migrator.CheckCurrent
+}
+
+// Overdue fails when overdue reports a scheduled job more than grace late.
+// overdue returns the most overdue job and how late it is at now.
+func Overdue(overdue func(now time.Time) (string, time.Duration), grace time.Duration) CheckFunc {
+	return func(ctx context.Context) error {
+		if name, late := overdue(time.Now()); late > grace {
+			return fmt.Errorf("%s has had no successful run for %s past its schedule (threshold %s)", name, late.Round(time.Second), grace)
+		}
+		return nil
+	}
+}
@@ -2,88 +2,234 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/felipepalacio293/stocks-app/buildinfo"
+	"github.com/felipepalacio293/stocks-app/cache"
 	"github.com/felipepalacio293/stocks-app/clients"
 	"github.com/felipepalacio293/stocks-app/config"
-	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/health"
+	"github.com/felipepalacio293/stocks-app/logging"
+	"github.com/felipepalacio293/stocks-app/metrics"
+	"github.com/felipepalacio293/stocks-app/migrations"
 	"github.com/felipepalacio293/stocks-app/repositories"
 	"github.com/felipepalacio293/stocks-app/routes"
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/felipepalacio293/stocks-app/sources"
 	"github.com/felipepalacio293/stocks-app/tasks"
+	"github.com/felipepalacio293/stocks-app/tracing"
 )
 
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Failed to load configuration %v", err)
+		fatal("Failed to load configuration", err)
+	}
+
+	logging.Setup(cfg.Environment, cfg.LogLevel)
+
+	info := buildinfo.Get()
+	slog.Info("Starting stocks-app", slog.String("version", info.Version), slog.String("commit", info.Commit))
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg)
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
 	}
 
 	db, err := config.InitDB(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize database %v", err)
+		fatal("Failed to initialize database", err)
+	}
+
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		fatal("Failed to register database tracing", err)
 	}
 
-	err = db.AutoMigrate(&models.Stock{})
+	migrator, err := migrations.NewMigrator(db, cfg.MigrationLockTimeout)
 	if err != nil {
-		log.Fatalf("Failed to migrate database %v", err)
+		fatal("Failed to load migrations", err)
+	}
+	if cfg.MigrateOnStart {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			fatal("Failed to migrate database", err)
+		}
+	}
+	if err := migrator.CheckCurrent(context.Background()); err != nil {
+		fatal("Database schema is not current, run stocksctl migrate up", err)
 	}
 
-	apiClient := clients.NewAPIClient(
-		cfg.APIBaseURL,
-		cfg.APIKey,
-	)
+	sqlDB, err := db.DB()
+	if err != nil {
+		fatal("Failed to get database handle", err)
+	}
+	if err := metrics.RegisterDB(sqlDB, cfg.DBName); err != nil {
+		slog.Warn("Failed to register database metrics", slog.Any("error", err))
+	}
+
+	registry, err := sources.FromConfig(cfg)
+	if err != nil {
+		fatal("Failed to configure rating sources", err)
+	}
 
 	stockRepo := repositories.NewStockRepository(db)
+	if err := services.KnownBrokerages.Load(context.Background(), stockRepo); err != nil {
+		fatal("Failed to load brokerages", err)
+	}
+	eventHub := services.NewStockEventHub(services.DefaultEventHistorySize)
+	responseCache := cache.NewResponseCache(cfg.CacheMaxEntries, cfg.CacheTTL)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	var tasksWG sync.WaitGroup
+
+	// Start runs an initial sync of every source right away and then follows
+	// SYNC_SCHEDULE, or the source's own entry in SYNC_SCHEDULES
 	syncTask := tasks.NewStockSyncTask(
 		stockRepo,
-		apiClient,
-		30*time.Minute,
+		registry,
+		eventHub,
+		responseCache,
+		cfg.SyncSchedule,
 	)
-	go syncTask.Start(ctx)
-	log.Println("Stock sync task started - will sync every 30 minutes")
-
+	for name, sourceSchedule := range cfg.SourceSchedules {
+		if err := syncTask.SetSchedule(name, sourceSchedule); err != nil {
+			fatal("Invalid SYNC_SCHEDULES", err)
+		}
+	}
+	tasksWG.Add(1)
 	go func() {
-		time.Sleep(2 * time.Second)
-		log.Println("Running initial stock sync...")
-		_, taskCancel := context.WithTimeout(ctx, 60*time.Second)
-		defer taskCancel()
-		syncTask.SyncStocks(ctx)
+		defer tasksWG.Done()
+		syncTask.Start(ctx)
 	}()
+	slog.Info("Stock sync task started", slog.String("schedule", cfg.SyncSchedule.String()), slog.Any("sources", registry.Names()))
+
+	if cfg.PricesDir != "" {
+		priceTask := tasks.NewPriceLoadTask(
+			repositories.NewPriceRepository(db),
+			clients.NewCSVPriceProvider(cfg.PricesDir),
+			responseCache,
+			cfg.PriceLoadInterval,
+		)
+		tasksWG.Add(1)
+		go func() {
+			defer tasksWG.Done()
+			priceTask.Start(ctx)
+		}()
+		slog.Info("Price load task started", slog.String("dir", cfg.PricesDir), slog.Duration("interval", cfg.PriceLoadInterval))
+	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	checker := health.NewChecker()
+	checker.Register(health.Check{Name: "database", Critical: true, Run: health.DBPing(sqlDB)})
+	checker.Register(health.Check{Name: "migrations", Critical: true, Run: health.Migrations(migrator)})
+	if cfg.SyncMaxAge > 0 {
+		// Stale data is still servable, a long vendor outage should show as
+		// degraded rather than take every replica out of rotation at once
+		checker.Register(health.Check{Name: "stock_sync", Run: health.Overdue(syncTask.Overdue, cfg.SyncMaxAge)})
+	}
+	for _, source := range registry.All() {
+		pinger, ok := source.(sources.Pinger)
+		if !ok {
+			continue
+		}
+		// Not critical: serving the data we already have beats taking every
+		// instance out of rotation while a vendor is down
+		checker.Register(health.Check{
+			Name:    "source_" + source.Name(),
+			Timeout: 5 * time.Second,
+			Run:     health.Cached(pinger.Ping, time.Minute),
+		})
+	}
 
+	server := &http.Server{
+		Addr:              ":" + cfg.ServerPort,
+		Handler:           routes.SetupRouter(db, cfg, eventHub, responseCache, checker, syncTask),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// SSE streams never finish on their own, close them so Shutdown can return
+	server.RegisterOnShutdown(eventHub.Close)
+
+	serverErr := make(chan error, 1)
 	go func() {
-		r := routes.SetupRouter(db, cfg)
-		log.Printf("Starting server on port %s", cfg.ServerPort)
-		err = r.Run(":" + cfg.ServerPort)
-		if err != nil {
-			log.Fatalf("Failed to start server: %v", err)
-			cancel()
+		slog.Info("Starting server", slog.String("port", cfg.ServerPort))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
 		}
 	}()
 
-	<-quit
-	log.Println("Shutdown signal received")
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	exitCode := 0
+	select {
+	case sig := <-quit:
+		slog.Info("Shutdown signal received", slog.String("signal", sig.String()))
+	case err := <-serverErr:
+		slog.Error("Server stopped unexpectedly", slog.Any("error", err))
+		exitCode = 1
+	}
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer shutdownCancel()
+	// Stop taking new requests and let in-flight ones finish
+	httpCtx, httpCancel := context.WithTimeout(context.Background(), cfg.HTTPShutdownTimeout)
+	defer httpCancel()
+	if err := server.Shutdown(httpCtx); err != nil {
+		slog.Warn("HTTP server did not drain in time", slog.Duration("timeout", cfg.HTTPShutdownTimeout), slog.Any("error", err))
+		server.Close()
+		exitCode = 1
+	} else {
+		slog.Info("HTTP server stopped")
+	}
 
+	// Background tasks stop at their next checkpoint, a sync that is writing
+	// a batch commits it before returning
 	cancel()
-	log.Println("Background tasks are shutting down...")
+	if !waitTimeout(&tasksWG, cfg.TaskShutdownTimeout) {
+		slog.Warn("Background tasks did not stop in time", slog.Duration("timeout", cfg.TaskShutdownTimeout))
+		exitCode = 1
+	} else {
+		slog.Info("Background tasks stopped")
+	}
+
+	if err := sqlDB.Close(); err != nil {
+		slog.Warn("Failed to close database connections", slog.Any("error", err))
+	}
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer flushCancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("Failed to flush traces", slog.Any("error", err))
+	}
+
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+	slog.Info("Graceful shutdown completed")
+}
+
+// waitTimeout reports whether wg finished before the timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
 	select {
-	case <-shutdownCtx.Done():
-		log.Println("Shutdown timed out")
-	case <-time.After(3 * time.Second):
-		log.Println("Graceful shutdown completed")
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
 }
+
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "stocks"
+
+var (
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	SyncRunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "runs_total",
+		Help:      "Stock sync runs, by rating source and outcome.",
+	}, []string{"source", "status"})
+
+	SyncDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "duration_seconds",
+		Help:      "Duration of stock sync runs, by rating source.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"source"})
+
+	SyncPagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "pages_total",
+		Help:      "Upstream pages fetched by the stock sync, by rating source.",
+	}, []string{"source"})
+
+	SyncRowsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "rows_upserted_total",
+		Help:      "Rows upserted by the stock sync, by rating source and result (inserted, updated, unchanged).",
+	}, []string{"source", "result"})
+
+	SyncParseFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "parse_failures_total",
+		Help:      "Upstream items skipped because a field could not be parsed, by field.",
+	}, []string{"field"})
+
+	SyncLastSuccessTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sync",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful stock sync, by rating source.",
+	}, []string{"source"})
+
+	UpstreamRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "upstream",
+		Name:      "requests_total",
+		Help:      "Requests made to the upstream ratings API, by status code or error.",
+	}, []string{"status"})
+
+	UpstreamRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "upstream",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of requests to the upstream ratings API.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	HTTPRateLimitedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by the rate limiter, by route group.",
+	}, []string{"group"})
+
+	HTTPCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "cache_requests_total",
+		Help:      "Cacheable requests by whether they were served from the response cache.",
+	}, []string{"result"})
+
+	DBRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "transaction_retries_total",
+		Help:      "Transactions retried after a transient database error, by error class.",
+	}, []string{"class"})
+)
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB, name string) error {
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, name))
+
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		return nil
+	}
+	return err
+}
+
+// RetryErrorClass buckets transient database errors into a small, stable set
+// of label values.
+func RetryErrorClass(err error) string {
+	if err == nil {
+		return "none"
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "restart transaction"),
+		strings.Contains(msg, "serialization failure"),
+		strings.Contains(msg, "40001"):
+		return "serialization"
+	case strings.Contains(msg, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(msg, "connection"):
+		return "connection"
+	case strings.Contains(msg, "read-only transaction"):
+		return "read_only"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuthMiddleware guards admin routes with the ADMIN_API_KEY bearer token.
+// Admin routes are disabled entirely when no key is configured.
+func AdminAuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.AdminAPIKey == "" {
+			c.Error(apperrors.New(apperrors.KindForbidden, "admin API is disabled"))
+			c.Abort()
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminAPIKey)) != 1 {
+			c.Error(apperrors.New(apperrors.KindUnauthorized, "invalid admin credentials"))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/gin-gonic/gin"
+)
+
+type corsPolicy struct {
+	allowAll bool
+	origins  map[string]bool
+	// Wildcard entries split around the "*", e.g. "https://" and ".example.com"
+	wildcards [][2]string
+
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// CORSMiddleware answers preflight requests and reflects the request origin
+// when it is in ALLOWED_ORIGINS. Requests from other origins are served
+// without CORS headers, so browsers block them.
+func CORSMiddleware(cfg *config.Config) gin.HandlerFunc {
+	policy := newCORSPolicy(cfg)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		if !policy.allows(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if policy.allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if policy.allowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", policy.allowMethods)
+			header.Set("Access-Control-Allow-Headers", policy.allowHeaders)
+			if policy.maxAge != "" {
+				header.Set("Access-Control-Max-Age", policy.maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if policy.exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", policy.exposeHeaders)
+		}
+
+		c.Next()
+	}
+}
+
+func newCORSPolicy(cfg *config.Config) *corsPolicy {
+	policy := &corsPolicy{
+		origins:       make(map[string]bool),
+		allowMethods:  strings.Join(cfg.CORSAllowedMethods, ", "),
+		allowHeaders:  strings.Join(cfg.CORSAllowedHeaders, ", "),
+		exposeHeaders: strings.Join(cfg.CORSExposedHeaders, ", "),
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			policy.allowAll = true
+		case strings.Contains(origin, "://*."):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			policy.wildcards = append(policy.wildcards, [2]string{prefix, suffix})
+		default:
+			policy.origins[origin] = true
+		}
+	}
+
+	// Browsers reject credentials on a "*" policy
+	policy.allowCredentials = cfg.CORSAllowCredentials && !policy.allowAll
+
+	if cfg.CORSMaxAge > 0 {
+		policy.maxAge = strconv.Itoa(int(cfg.CORSMaxAge.Seconds()))
+	}
+
+	return policy
+}
+
+func (p *corsPolicy) allows(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+
+	for _, wildcard := range p.wildcards {
+		prefix, suffix := wildcard[0], wildcard[1]
+		if len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ErrorFormatLegacy  = "legacy"
+	ErrorFormatProblem = "problem"
+
+	problemContentType = "application/problem+json"
+)
+
+// ErrorMiddleware renders the last error a handler attached with c.Error.
+// Clients asking for application/problem+json (or every client, with
+// ERROR_FORMAT=problem) get RFC 7807 bodies, everyone else the legacy
+// utils.Response envelope. Internal errors are logged, never returned.
+func ErrorMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		ctx := c.Request.Context()
+		appErr := apperrors.From(c.Errors.Last().Err)
+		status := appErr.Kind.Status()
+
+		if status >= http.StatusInternalServerError {
+			slog.ErrorContext(ctx, "Request failed",
+				slog.String("code", string(appErr.Kind)),
+				slog.Any("error", appErr))
+		}
+
+		if cfg.ErrorFormat == ErrorFormatProblem || strings.Contains(c.GetHeader("Accept"), problemContentType) {
+			c.Render(status, problemRender{utils.ProblemResponse(ctx, appErr, c.Request.URL.Path)})
+			return
+		}
+
+		response := utils.ErrorResponse(ctx, appErr.Message)
+		response.Data = appErr.Data
+		c.JSON(status, response)
+	}
+}
+
+type problemRender struct {
+	problem utils.Problem
+}
+
+func (r problemRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.problem)
+}
+
+func (r problemRender) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", problemContentType)
+}
@@ -3,28 +3,72 @@ package middlewares
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/felipepalacio293/stocks-app/config"
 	"github.com/gin-gonic/gin"
 )
 
+const redactedValue = "[REDACTED]"
+
+var redactedHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"x-admin-key":         true,
+}
+
+var redactedFields = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"api_key":       true,
+	"apikey":        true,
+	"secret":        true,
+	"authorization": true,
+}
+
+// redactedFieldPattern is the fallback for bodies that are not valid JSON, such
+// as ones cut at the size limit.
+var redactedFieldPattern = regexp.MustCompile(`(?i)("(?:password|token|access_token|refresh_token|api_key|apikey|secret|authorization)"\s*:\s*)"[^"]*"?`)
+
+// ResponseWriter keeps a copy of at most limit bytes of the response body.
 type ResponseWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body  *bytes.Buffer
+	limit int
 }
 
 func (w *ResponseWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	w.capture(b)
 	return w.ResponseWriter.Write(b)
 }
 
 func (w *ResponseWriter) WriteString(s string) (int, error) {
-	w.body.WriteString(s)
+	w.capture([]byte(s))
 	return w.ResponseWriter.WriteString(s)
 }
 
+func (w *ResponseWriter) capture(b []byte) {
+	if remaining := w.limit - w.body.Len(); remaining > 0 {
+		if len(b) > remaining {
+			b = b[:remaining]
+		}
+		w.body.Write(b)
+	}
+}
+
 func LoggerMiddleware(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !cfg.EnableRequestLogs {
@@ -33,49 +77,176 @@ func LoggerMiddleware(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		start := time.Now()
+		captureBodies := cfg.Environment != "production" && cfg.LogBodyMaxBytes > 0
 
 		var requestBody []byte
-		if c.Request.Body != nil {
-			requestBody, _ = io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		if captureBodies && c.Request.Body != nil && isLoggableContentType(c.GetHeader("Content-Type")) {
+			// Only buffer up to the limit, the rest of the body is streamed untouched
+			requestBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, int64(cfg.LogBodyMaxBytes)))
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(requestBody), c.Request.Body), c.Request.Body}
 		}
 
-		responseWriter := &ResponseWriter{
-			ResponseWriter: c.Writer,
-			body:           bytes.NewBufferString(""),
+		var responseWriter *ResponseWriter
+		if captureBodies {
+			responseWriter = &ResponseWriter{
+				ResponseWriter: c.Writer,
+				body:           bytes.NewBufferString(""),
+				limit:          cfg.LogBodyMaxBytes,
+			}
+			c.Writer = responseWriter
 		}
-		c.Writer = responseWriter
 
 		c.Next()
 
+		status := c.Writer.Status()
+		if status < http.StatusBadRequest && !sampled(cfg.LogSuccessSampleRate) {
+			return
+		}
+
 		latency := time.Since(start)
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.String("query", redactQuery(c.Request.URL.RawQuery)),
+			slog.Int("status", status),
+			slog.Duration("latency", latency),
+			slog.Int("response_size", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
 
-		if cfg.Environment != "production" {
-			logRequest := map[string]interface{}{
-				"timestamp":    time.Now().Format(time.RFC3339),
-				"method":       c.Request.Method,
-				"path":         c.Request.URL.Path,
-				"query":        c.Request.URL.RawQuery,
-				"status":       c.Writer.Status(),
-				"latency":      latency.String(),
-				"request_body": string(requestBody),
-				"response":     responseWriter.body.String(),
-				"client_ip":    c.ClientIP(),
-				"user_agent":   c.Request.UserAgent(),
+		if captureBodies {
+			attrs = append(attrs,
+				slog.Any("headers", redactHeaders(c.Request.Header)),
+				slog.String("request_body", formatBody(requestBody, c.Request.ContentLength, cfg.LogBodyMaxBytes)),
+			)
+			if isLoggableContentType(c.Writer.Header().Get("Content-Type")) {
+				attrs = append(attrs, slog.String("response_body",
+					formatBody(responseWriter.body.Bytes(), int64(c.Writer.Size()), cfg.LogBodyMaxBytes)))
 			}
-			logRequestJSON, _ := json.Marshal(gin.H{"request": logRequest})
-			gin.DefaultWriter.Write([]byte(string(logRequestJSON) + "\n"))
-		} else {
-			logRequest := map[string]interface{}{
-				"timestamp": time.Now().Format(time.RFC3339),
-				"method":    c.Request.Method,
-				"path":      c.Request.URL.Path,
-				"status":    c.Writer.Status(),
-				"latency":   latency.String(),
-				"client_ip": c.ClientIP(),
+		}
+
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+
+		slog.LogAttrs(c.Request.Context(), level, "http request", attrs...)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func sampled(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	return rand.Float64() < rate
+}
+
+// isLoggableContentType skips binary, multipart and streaming bodies, which
+// are either useless in logs or would never finish.
+func isLoggableContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	contentType = strings.ToLower(contentType)
+	return strings.Contains(contentType, "json") ||
+		strings.HasPrefix(contentType, "text/plain") ||
+		strings.HasPrefix(contentType, "application/x-www-form-urlencoded")
+}
+
+func formatBody(body []byte, size int64, limit int) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	// size is -1 when the request had no Content-Length
+	truncated := size > int64(len(body)) || (size < 0 && len(body) >= limit)
+
+	if !truncated {
+		if redacted, ok := redactJSON(body); ok {
+			return string(redacted)
+		}
+	}
+
+	body = redactedFieldPattern.ReplaceAll(body, []byte(`${1}"`+redactedValue+`"`))
+	if truncated {
+		return fmt.Sprintf("%s... [truncated, %d bytes total]", body, size)
+	}
+	return string(body)
+}
+
+// redactJSON masks sensitive fields at any depth. Bodies that are not complete
+// JSON documents (e.g. truncated ones) are reported with ok set to false.
+func redactJSON(body []byte) ([]byte, bool) {
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, false
+	}
+
+	redacted, err := json.Marshal(redactValue(payload))
+	if err != nil {
+		return nil, false
+	}
+	return redacted, true
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if redactedFields[strings.ToLower(key)] {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(inner)
 			}
-			logRequestJSON, _ := json.Marshal(gin.H{"request": logRequest})
-			gin.DefaultWriter.Write([]byte(string(logRequestJSON) + "\n"))
+		}
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redactValue(inner)
+		}
+	}
+	return value
+}
+
+func redactHeaders(headers http.Header) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if redactedHeaders[strings.ToLower(name)] {
+			redacted[name] = redactedValue
+		} else {
+			redacted[name] = strings.Join(values, ", ")
+		}
+	}
+	return redacted
+}
+
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	// A query that does not parse could still hold a secret
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redactedValue
+	}
+
+	for key := range values {
+		if redactedFields[strings.ToLower(key)] {
+			values[key] = []string{redactedValue}
 		}
 	}
+	return values.Encode()
 }
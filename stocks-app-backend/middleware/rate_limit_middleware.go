@@ -0,0 +1,176 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/felipepalacio293/stocks-app/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter is a token bucket per client: buckets hold up to capacity
+// tokens and refill at rate tokens per second.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	capacity  float64
+	rate      float64
+	lastSweep time.Time
+}
+
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// RateLimitMiddleware limits each client of a route group to the rate set for
+// the group in RATE_LIMITS. Clients sending one of RATE_LIMIT_API_KEYS are
+// identified by that key, all others by IP, so a client cannot get a fresh
+// bucket by sending a new key on every request.
+func RateLimitMiddleware(cfg *config.Config, group string) gin.HandlerFunc {
+	return rateLimitMiddleware(cfg, group, apiKeyRateLimitKey(cfg.RateLimitAPIKeys))
+}
+
+// IPRateLimitMiddleware is RateLimitMiddleware keyed by IP only, for routes
+// whose credentials are not rate limit keys.
+func IPRateLimitMiddleware(cfg *config.Config, group string) gin.HandlerFunc {
+	return rateLimitMiddleware(cfg, group, ipRateLimitKey)
+}
+
+func rateLimitMiddleware(cfg *config.Config, group string, key func(*gin.Context) string) gin.HandlerFunc {
+	limit, ok := cfg.RateLimits[group]
+	if !ok {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	limiter := newRateLimiter(limit)
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(math.Ceil(limit.Per.Seconds())))
+
+	return func(c *gin.Context) {
+		result := limiter.allow(key(c), time.Now())
+
+		header := c.Writer.Header()
+		header.Set("RateLimit-Policy", policy)
+		header.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+
+		if !result.allowed {
+			retryAfter := ceilSeconds(result.retryAfter)
+			header.Set("Retry-After", strconv.Itoa(retryAfter))
+			metrics.HTTPRateLimitedTotal.WithLabelValues(group).Inc()
+			c.Error(apperrors.New(apperrors.KindRateLimited, fmt.Sprintf("rate limit exceeded, retry in %d seconds", retryAfter)))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func apiKeyRateLimitKey(keys []string) func(*gin.Context) string {
+	return func(c *gin.Context) string {
+		apiKey := c.GetHeader("X-API-Key")
+		if apiKey == "" {
+			apiKey, _ = strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if apiKey == "" || !knownAPIKey(keys, apiKey) {
+			return ipRateLimitKey(c)
+		}
+
+		// Buckets outlive requests, keep a digest rather than the key itself
+		sum := sha256.Sum256([]byte(apiKey))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+}
+
+func knownAPIKey(keys []string, apiKey string) bool {
+	known := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+			known = true
+		}
+	}
+	return known
+}
+
+func ipRateLimitKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+func newRateLimiter(limit config.RateLimit) *rateLimiter {
+	return &rateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		capacity:  float64(limit.Requests),
+		rate:      float64(limit.Requests) / limit.Per.Seconds(),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *rateLimiter) allow(key string, now time.Time) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.capacity, updated: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(l.capacity, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+
+	result := rateLimitResult{allowed: bucket.tokens >= 1}
+	if result.allowed {
+		bucket.tokens--
+	} else {
+		result.retryAfter = l.durationFor(1 - bucket.tokens)
+	}
+
+	result.remaining = int(bucket.tokens)
+	result.reset = l.durationFor(l.capacity - bucket.tokens)
+	return result
+}
+
+// sweep drops buckets that have refilled completely, they are identical to a
+// new one and would otherwise pile up for every IP ever seen.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.capacity {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *rateLimiter) durationFor(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitMiddlewareKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type request struct {
+		ip     string
+		header string
+		value  string
+		want   int
+	}
+	cases := []struct {
+		name     string
+		requests []request
+	}{
+		{"rotating unknown keys share the IP bucket", []request{
+			{"10.0.0.1", "X-API-Key", "guess-1", http.StatusOK},
+			{"10.0.0.1", "X-API-Key", "guess-2", http.StatusOK},
+			{"10.0.0.1", "Authorization", "Bearer guess-3", http.StatusTooManyRequests},
+		}},
+		{"configured key has a bucket of its own", []request{
+			{"10.0.0.1", "", "", http.StatusOK},
+			{"10.0.0.1", "", "", http.StatusOK},
+			{"10.0.0.1", "X-API-Key", "partner-key", http.StatusOK},
+			{"10.0.0.1", "Authorization", "Bearer partner-key", http.StatusOK},
+			{"10.0.0.2", "X-API-Key", "partner-key", http.StatusTooManyRequests},
+			{"10.0.0.1", "", "", http.StatusTooManyRequests},
+		}},
+		{"clients are limited per IP", []request{
+			{"10.0.0.1", "", "", http.StatusOK},
+			{"10.0.0.1", "", "", http.StatusOK},
+			{"10.0.0.1", "", "", http.StatusTooManyRequests},
+			{"10.0.0.2", "", "", http.StatusOK},
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Environment:      "test",
+				RateLimits:       map[string]config.RateLimit{"stocks": {Requests: 2, Per: time.Hour}},
+				RateLimitAPIKeys: []string{"partner-key", "other-key"},
+			}
+			r := gin.New()
+			r.Use(ErrorMiddleware(cfg), RateLimitMiddleware(cfg, "stocks"))
+			r.GET("/stocks", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			for i, req := range tc.requests {
+				httpReq := httptest.NewRequest(http.MethodGet, "/stocks", nil)
+				httpReq.RemoteAddr = req.ip + ":40000"
+				if req.header != "" {
+					httpReq.Header.Set(req.header, req.value)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httpReq)
+
+				if w.Code != req.want {
+					t.Fatalf("request %d from %s with %s %q = %d, want %d", i, req.ip, req.header, req.value, w.Code, req.want)
+				}
+			}
+		})
+	}
+}
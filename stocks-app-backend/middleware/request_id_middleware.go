@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"log/slog"
+	"regexp"
+
+	"github.com/felipepalacio293/stocks-app/logging"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Incoming IDs end up in logs and headers, so anything unusual is replaced
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware accepts the caller's X-Request-ID or generates one,
+// stores it in the request context for logs, error bodies and outbound calls,
+// and echoes it back in the response.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(utils.RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		ctx := utils.WithRequestID(c.Request.Context(), requestID)
+		ctx = logging.WithContext(ctx, slog.String("request_id", requestID))
+		c.Request = c.Request.WithContext(ctx)
+
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+
+		c.Set("request_id", requestID)
+		c.Header(utils.RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
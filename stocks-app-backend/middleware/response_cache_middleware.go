@@ -0,0 +1,119 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/cache"
+	"github.com/felipepalacio293/stocks-app/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// ResponseCacheMiddleware serves GET responses from responseCache and adds
+// ETag/Cache-Control so clients can revalidate with If-None-Match. Only 200
+// responses are cached, anything else is passed through untouched.
+func ResponseCacheMiddleware(responseCache *cache.ResponseCache, maxAge time.Duration) gin.HandlerFunc {
+	cacheControl := "public, no-cache"
+	if maxAge > 0 {
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		key := cacheKey(c.Request.URL)
+		if entry, ok := responseCache.Get(key); ok {
+			metrics.HTTPCacheRequestsTotal.WithLabelValues("hit").Inc()
+			serveCached(c, entry, cacheControl)
+			c.Abort()
+			return
+		}
+		metrics.HTTPCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+		version := responseCache.Version()
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		c.Writer = writer.ResponseWriter
+		if len(c.Errors) > 0 && writer.body.Len() == 0 {
+			// Nothing was rendered, ErrorMiddleware writes the response
+			return
+		}
+		if c.Writer.Status() != http.StatusOK {
+			c.Writer.WriteHeaderNow()
+			c.Writer.Write(writer.body.Bytes())
+			return
+		}
+
+		body := writer.body.Bytes()
+		sum := sha256.Sum256(body)
+		entry := &cache.Entry{
+			ContentType: c.Writer.Header().Get("Content-Type"),
+			Body:        body,
+			ETag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		}
+		responseCache.Set(key, version, entry)
+		serveCached(c, entry, cacheControl)
+	}
+}
+
+func serveCached(c *gin.Context, entry *cache.Entry, cacheControl string) {
+	c.Header("ETag", entry.ETag)
+	c.Header("Cache-Control", cacheControl)
+
+	if etagMatches(c.GetHeader("If-None-Match"), entry.ETag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	c.Data(http.StatusOK, entry.ContentType, entry.Body)
+}
+
+// cacheKey normalizes the query so parameter order doesn't split the cache.
+func cacheKey(u *url.URL) string {
+	values := u.Query()
+	for _, v := range values {
+		sort.Strings(v)
+	}
+	return u.Path + "?" + values.Encode()
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
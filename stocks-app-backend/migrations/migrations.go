@@ -0,0 +1,402 @@
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Migration files live in sql/<dialect>/ and are named NNNN_description.up.sql
+// and NNNN_description.down.sql, every dialect must have the same versions.
+// Statements are split on semicolons at the end of a line, so a file must not
+// contain one inside a string literal.
+//
+//go:embed sql
+var files embed.FS
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+const (
+	migrationsTable = "schema_migrations"
+	lockTable       = "schema_migrations_lock"
+
+	// A lock older than this is assumed to belong to a process that died
+	// without releasing it
+	staleLockAge = 10 * time.Minute
+	lockPollWait = time.Second
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	db          *gorm.DB
+	dialect     string
+	migrations  []Migration
+	lockTimeout time.Duration
+	holder      string
+}
+
+func NewMigrator(db *gorm.DB, lockTimeout time.Duration) (*Migrator, error) {
+	dialect := db.Dialector.Name()
+	migrations, err := load(files, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &Migrator{
+		db:          db,
+		dialect:     dialect,
+		migrations:  migrations,
+		lockTimeout: lockTimeout,
+		holder:      fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), uuid.NewString()[:8]),
+	}, nil
+}
+
+func load(fsys fs.FS, dialect string) ([]Migration, error) {
+	dir := path.Join("sql", dialect)
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for database dialect %s: %w", dialect, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in order, each one in its own
+// transaction, and returns the ones it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func() error {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range pending {
+			slog.InfoContext(ctx, "Applying migration", slog.Int("version", migration.Version), slog.String("name", migration.Name))
+			err := m.apply(ctx, migration.Up, func(tx *gorm.DB) error {
+				return tx.Exec("INSERT INTO "+migrationsTable+" (version, name, applied_at) VALUES (?, ?, ?)",
+					migration.Version, migration.Name, time.Now().UTC()).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func() error {
+		versions, err := m.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s cannot be rolled back, it has no down file", migration.Version, migration.Name)
+			}
+
+			slog.InfoContext(ctx, "Rolling back migration", slog.Int("version", migration.Version), slog.String("name", migration.Name))
+			err := m.apply(ctx, migration.Down, func(tx *gorm.DB) error {
+				return tx.Exec("DELETE FROM "+migrationsTable+" WHERE version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+
+	return reverted, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	versions, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	versions, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := versions[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// CheckCurrent fails when migrations are pending, or when the database has
+// versions this binary does not know about, i.e. it was migrated by a newer
+// release.
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	versions, err := m.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if _, ok := versions[migration.Version]; !ok {
+			return fmt.Errorf("migration %d_%s is pending", migration.Version, migration.Name)
+		}
+	}
+	for version := range versions {
+		if !known[version] {
+			return fmt.Errorf("database has unknown migration %d applied", version)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, script string, record func(tx *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(script) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]time.Time, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Version   int
+		AppliedAt time.Time
+	}
+	err := m.db.WithContext(ctx).Raw("SELECT version, applied_at FROM " + migrationsTable).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row.AppliedAt
+	}
+	return versions, nil
+}
+
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	// SQLite only parses DATETIME columns back into time values
+	timestampType := "TIMESTAMPTZ"
+	if m.dialect == "sqlite" {
+		timestampType = "DATETIME"
+	}
+
+	db := m.db.WithContext(ctx)
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS " + migrationsTable + " (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at " + timestampType + " NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS " + lockTable + " (id INT PRIMARY KEY, locked_by VARCHAR(255) NOT NULL, locked_at " + timestampType + " NOT NULL)",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			// Replicas starting together can race on CREATE TABLE IF NOT EXISTS,
+			// losing that race is fine as long as the table is there
+			if !db.Migrator().HasTable(tableName(statement)) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// withLock runs fn while holding the row in schema_migrations_lock, so only
+// one of several replicas starting at once applies migrations. The others wait
+// and then find nothing pending.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+
+	if err := m.acquire(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		// Released even if ctx was cancelled, otherwise the next start waits
+		// for the lock to go stale
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		err := m.db.WithContext(releaseCtx).
+			Exec("DELETE FROM "+lockTable+" WHERE id = 1 AND locked_by = ?", m.holder).Error
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to release migration lock", slog.Any("error", err))
+		}
+	}()
+
+	return fn()
+}
+
+func (m *Migrator) acquire(ctx context.Context) error {
+	deadline := time.Now().Add(m.lockTimeout)
+	db := m.db.WithContext(ctx)
+
+	for {
+		now := time.Now().UTC()
+		err := db.Exec("INSERT INTO "+lockTable+" (id, locked_by, locked_at) VALUES (1, ?, ?)", m.holder, now).Error
+		if err == nil {
+			return nil
+		}
+
+		var lock struct {
+			LockedBy string
+			LockedAt time.Time
+		}
+		if lookupErr := db.Raw("SELECT locked_by, locked_at FROM " + lockTable + " WHERE id = 1").Scan(&lock).Error; lookupErr != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", errors.Join(err, lookupErr))
+		}
+
+		if lock.LockedBy != "" && now.Sub(lock.LockedAt) > staleLockAge {
+			slog.WarnContext(ctx, "Removing stale migration lock",
+				slog.String("locked_by", lock.LockedBy), slog.Time("locked_at", lock.LockedAt))
+			db.Exec("DELETE FROM "+lockTable+" WHERE id = 1 AND locked_by = ?", lock.LockedBy)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for the migration lock held by %s", m.lockTimeout, lock.LockedBy)
+		}
+
+		slog.InfoContext(ctx, "Waiting for migration lock", slog.String("locked_by", lock.LockedBy))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollWait):
+		}
+	}
+}
+
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
+func tableName(createStatement string) string {
+	fields := strings.Fields(strings.TrimPrefix(createStatement, "CREATE TABLE IF NOT EXISTS "))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS rating_events;
+DROP TABLE IF EXISTS prices;
+DROP TABLE IF EXISTS stocks;
@@ -0,0 +1,55 @@
+-- Schema previously created by GORM AutoMigrate. IF NOT EXISTS lets databases
+-- created that way adopt this migration without changes.
+
+CREATE TABLE IF NOT EXISTS stocks (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    ticker VARCHAR(20),
+    company VARCHAR(255),
+    brokerage VARCHAR(100),
+    action VARCHAR(50),
+    rating_from VARCHAR(50),
+    rating_to VARCHAR(50),
+    target_from DECIMAL(10,2),
+    target_to DECIMAL(10,2),
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stocks_deleted_at ON stocks (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_stock_ticker ON stocks (ticker);
+CREATE INDEX IF NOT EXISTS idx_stock_company ON stocks (company);
+
+CREATE TABLE IF NOT EXISTS prices (
+    ticker VARCHAR(20) NOT NULL,
+    date DATE NOT NULL,
+    open DECIMAL(12,4),
+    high DECIMAL(12,4),
+    low DECIMAL(12,4),
+    close DECIMAL(12,4),
+    volume BIGINT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY (ticker, date)
+);
+
+CREATE TABLE IF NOT EXISTS rating_events (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    stock_id UUID,
+    event_time TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    ticker VARCHAR(20),
+    company VARCHAR(255),
+    brokerage VARCHAR(100),
+    action VARCHAR(50),
+    rating_from VARCHAR(50),
+    rating_to VARCHAR(50),
+    target_from DECIMAL(10,2),
+    target_to DECIMAL(10,2),
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_events_stock_id ON rating_events (stock_id);
+CREATE INDEX IF NOT EXISTS idx_rating_event_time ON rating_events (event_time);
+CREATE INDEX IF NOT EXISTS idx_rating_event_ticker ON rating_events (ticker);
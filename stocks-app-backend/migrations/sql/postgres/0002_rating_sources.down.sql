@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_stock_source_key;
+
+ALTER TABLE rating_events DROP COLUMN source;
+ALTER TABLE stocks DROP COLUMN source;
@@ -0,0 +1 @@
+ALTER TABLE stocks DROP COLUMN rated_at;
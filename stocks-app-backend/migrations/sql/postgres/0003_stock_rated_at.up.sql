@@ -0,0 +1,4 @@
+-- When the source published the rating, empty for rows synced before it was
+-- stored and for sources that do not report it.
+
+ALTER TABLE stocks ADD COLUMN rated_at TIMESTAMPTZ;
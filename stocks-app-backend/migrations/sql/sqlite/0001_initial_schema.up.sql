@@ -0,0 +1,56 @@
+-- SQLite version of postgres/0001_initial_schema.up.sql. IDs are generated by
+-- the application, and DATETIME/DATE are the declared types the driver parses
+-- back into time values.
+
+CREATE TABLE IF NOT EXISTS stocks (
+    id TEXT NOT NULL,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    ticker VARCHAR(20),
+    company VARCHAR(255),
+    brokerage VARCHAR(100),
+    action VARCHAR(50),
+    rating_from VARCHAR(50),
+    rating_to VARCHAR(50),
+    target_from DECIMAL(10,2),
+    target_to DECIMAL(10,2),
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stocks_deleted_at ON stocks (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_stock_ticker ON stocks (ticker);
+CREATE INDEX IF NOT EXISTS idx_stock_company ON stocks (company);
+
+CREATE TABLE IF NOT EXISTS prices (
+    ticker VARCHAR(20) NOT NULL,
+    date DATE NOT NULL,
+    open DECIMAL(12,4),
+    high DECIMAL(12,4),
+    low DECIMAL(12,4),
+    close DECIMAL(12,4),
+    volume BIGINT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    PRIMARY KEY (ticker, date)
+);
+
+CREATE TABLE IF NOT EXISTS rating_events (
+    id TEXT NOT NULL,
+    stock_id TEXT,
+    event_time DATETIME,
+    created_at DATETIME,
+    ticker VARCHAR(20),
+    company VARCHAR(255),
+    brokerage VARCHAR(100),
+    action VARCHAR(50),
+    rating_from VARCHAR(50),
+    rating_to VARCHAR(50),
+    target_from DECIMAL(10,2),
+    target_to DECIMAL(10,2),
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_events_stock_id ON rating_events (stock_id);
+CREATE INDEX IF NOT EXISTS idx_rating_event_time ON rating_events (event_time);
+CREATE INDEX IF NOT EXISTS idx_rating_event_ticker ON rating_events (ticker);
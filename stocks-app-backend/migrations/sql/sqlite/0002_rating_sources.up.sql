@@ -0,0 +1,7 @@
+-- Rows stored before rating sources existed all came from the challenge API
+-- (or admin imports, which cannot be told apart anymore).
+
+ALTER TABLE stocks ADD COLUMN source VARCHAR(50) NOT NULL DEFAULT 'swechallenge';
+ALTER TABLE rating_events ADD COLUMN source VARCHAR(50) NOT NULL DEFAULT 'swechallenge';
+
+CREATE INDEX IF NOT EXISTS idx_stock_source_key ON stocks (source, ticker, brokerage);
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Price struct {
+	Ticker    string    `json:"ticker" gorm:"primaryKey;size:20"`
+	Date      time.Time `json:"date" gorm:"primaryKey;type:date"`
+	Open      float64   `json:"open" gorm:"type:decimal(12,4)"`
+	High      float64   `json:"high" gorm:"type:decimal(12,4)"`
+	Low       float64   `json:"low" gorm:"type:decimal(12,4)"`
+	Close     float64   `json:"close" gorm:"type:decimal(12,4)"`
+	Volume    int64     `json:"volume"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Price) TableName() string {
+	return "prices"
+}
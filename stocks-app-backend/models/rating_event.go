@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RatingEvent is an append-only record of every rating change observed for a
+// stock row, the stocks table itself only keeps the latest state.
+type RatingEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	StockID   uuid.UUID `gorm:"type:uuid;index" json:"stock_id"`
+	EventTime time.Time `gorm:"index:idx_rating_event_time" json:"event_time"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Source    string `json:"source" gorm:"size:50"`
+	Ticker    string `json:"ticker" gorm:"size:20;index:idx_rating_event_ticker"`
+	Company   string `json:"company" gorm:"size:255"`
+	Brokerage string `json:"brokerage" gorm:"size:100"`
+
+	Action     string `json:"action" gorm:"size:50"`
+	RatingFrom string `json:"rating_from" gorm:"size:50"`
+	RatingTo   string `json:"rating_to" gorm:"size:50"`
+
+	TargetFrom float64 `json:"target_from" gorm:"type:decimal(10,2)"`
+	TargetTo   float64 `json:"target_to" gorm:"type:decimal(10,2)"`
+}
+
+func (RatingEvent) TableName() string {
+	return "rating_events"
+}
+
+func (e *RatingEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+func NewRatingEvent(stock Stock, eventTime time.Time) RatingEvent {
+	return RatingEvent{
+		StockID:    stock.ID,
+		EventTime:  eventTime,
+		Source:     stock.Source,
+		Ticker:     stock.Ticker,
+		Company:    stock.Company,
+		Brokerage:  stock.Brokerage,
+		Action:     stock.Action,
+		RatingFrom: stock.RatingFrom,
+		RatingTo:   stock.RatingTo,
+		TargetFrom: stock.TargetFrom,
+		TargetTo:   stock.TargetTo,
+	}
+}
+
+// ToStock rebuilds the stock row as it looked right after this event.
+func (e RatingEvent) ToStock() Stock {
+	return Stock{
+		ID:         e.StockID,
+		CreatedAt:  e.EventTime,
+		UpdatedAt:  e.EventTime,
+		Source:     e.Source,
+		Ticker:     e.Ticker,
+		Company:    e.Company,
+		Brokerage:  e.Brokerage,
+		Action:     e.Action,
+		RatingFrom: e.RatingFrom,
+		RatingTo:   e.RatingTo,
+		TargetFrom: e.TargetFrom,
+		TargetTo:   e.TargetTo,
+		RatedAt:    &e.EventTime,
+	}
+}
@@ -1,18 +1,23 @@
 package models
 
 import (
+	"strconv"
 	"time"
 
+	"github.com/felipepalacio293/stocks-app/utils"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type Stock struct {
-	ID        uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Source is the name of the rating source the row was synced from, rows
+	// are unique by source, ticker and brokerage
+	Source    string `json:"source" gorm:"size:50"`
 	Ticker    string `json:"ticker" gorm:"size:20;index:idx_stock_ticker"`
 	Company   string `json:"company" gorm:"size:255;index:idx_stock_company"`
 	Brokerage string `json:"brokerage" gorm:"size:100;column:brokerage"`
@@ -23,12 +28,25 @@ type Stock struct {
 
 	TargetFrom float64 `json:"target_from" gorm:"type:decimal(10,2)"`
 	TargetTo   float64 `json:"target_to" gorm:"type:decimal(10,2)"`
+
+	// RatedAt is when the source published the rating, nil when it does not
+	// report it
+	RatedAt *time.Time `json:"rated_at"`
 }
 
 func (Stock) TableName() string {
 	return "stocks"
 }
 
+// RatingTime is when the rating was published, or when the row was last
+// changed for sources that do not report it.
+func (s Stock) RatingTime() time.Time {
+	if s.RatedAt != nil {
+		return *s.RatedAt
+	}
+	return s.UpdatedAt
+}
+
 func (s *Stock) BeforeCreate(tx *gorm.DB) error {
 	if s.ID == uuid.Nil {
 		s.ID = uuid.New()
@@ -38,6 +56,7 @@ func (s *Stock) BeforeCreate(tx *gorm.DB) error {
 
 type StockResponse struct {
 	ID         uuid.UUID `json:"id"`
+	Source     string    `json:"source"`
 	Ticker     string    `json:"ticker"`
 	Company    string    `json:"company"`
 	Brokerage  string    `json:"brokerage"`
@@ -46,6 +65,8 @@ type StockResponse struct {
 	RatingTo   string    `json:"rating_to"`
 	TargetFrom float64   `json:"target_from"`
 	TargetTo   float64   `json:"target_to"`
+	LastClose  *float64  `json:"last_close"`
+	Upside     *float64  `json:"upside"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
@@ -53,6 +74,7 @@ type StockResponse struct {
 func (s *Stock) ToResponse() StockResponse {
 	return StockResponse{
 		ID:         s.ID,
+		Source:     s.Source,
 		Ticker:     s.Ticker,
 		Company:    s.Company,
 		Brokerage:  s.Brokerage,
@@ -65,3 +87,42 @@ func (s *Stock) ToResponse() StockResponse {
 		UpdatedAt:  s.UpdatedAt,
 	}
 }
+
+// WithLastClose attaches the latest close and the implied upside to target.
+func (s StockResponse) WithLastClose(lastClose *float64) StockResponse {
+	s.LastClose = lastClose
+	s.Upside = ImpliedUpside(s.TargetTo, lastClose)
+	return s
+}
+
+// ImpliedUpside returns TargetTo / lastClose - 1, or nil when there is no usable close.
+func ImpliedUpside(targetTo float64, lastClose *float64) *float64 {
+	if lastClose == nil || *lastClose <= 0 || targetTo <= 0 {
+		return nil
+	}
+	upside := targetTo / *lastClose - 1
+	return &upside
+}
+
+func (StockResponse) CSVHeader() []string {
+	return []string{"id", "ticker", "company", "brokerage", "action", "rating_from", "rating_to", "target_from", "target_to", "last_close", "upside", "created_at", "updated_at", "source"}
+}
+
+func (s StockResponse) CSVRecord() []string {
+	return []string{
+		s.ID.String(),
+		s.Ticker,
+		s.Company,
+		s.Brokerage,
+		s.Action,
+		s.RatingFrom,
+		s.RatingTo,
+		strconv.FormatFloat(s.TargetFrom, 'f', 2, 64),
+		strconv.FormatFloat(s.TargetTo, 'f', 2, 64),
+		utils.FormatOptionalFloat(s.LastClose, 4),
+		utils.FormatOptionalFloat(s.Upside, 4),
+		s.CreatedAt.Format(time.RFC3339),
+		s.UpdatedAt.Format(time.RFC3339),
+		s.Source,
+	}
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// latestClosesSQL selects the most recent close per ticker, it is shared by the
+// price lookups and the stock listing join so both agree on what "last" means.
+const latestClosesSQL = `SELECT p.ticker, p.close, p.date FROM prices p
+	JOIN (SELECT ticker, MAX(date) AS date FROM prices GROUP BY ticker) l
+	ON l.ticker = p.ticker AND l.date = p.date`
+
+type PriceRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceRepository(db *gorm.DB) *PriceRepository {
+	return &PriceRepository{db: db}
+}
+
+func (r *PriceRepository) Upsert(ctx context.Context, prices []models.Price, batchSize int) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	prices = dedupePrices(prices)
+
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ticker"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "updated_at"}),
+	}).CreateInBatches(&prices, batchSize).Error
+}
+
+// ListBetween returns the bars within [from, to], ordered by ticker and date.
+func (r *PriceRepository) ListBetween(ctx context.Context, from, to time.Time) ([]models.Price, error) {
+	var prices []models.Price
+	err := r.db.WithContext(ctx).
+		Where("date >= ? AND date <= ?", from, to).
+		Order("ticker ASC, date ASC").
+		Find(&prices).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// LatestCloses returns the last known close per ticker. Tickers without price
+// history are absent from the map.
+func (r *PriceRepository) LatestCloses(ctx context.Context, tickers []string) (map[string]float64, error) {
+	closes := make(map[string]float64)
+	if len(tickers) == 0 {
+		return closes, nil
+	}
+
+	var rows []struct {
+		Ticker string
+		Close  float64
+	}
+
+	err := r.db.WithContext(ctx).
+		Table("(?) AS lp", gorm.Expr(latestClosesSQL)).
+		Select("lp.ticker, lp.close").
+		Where("lp.ticker IN ?", tickers).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		closes[row.Ticker] = row.Close
+	}
+
+	return closes, nil
+}
+
+// dedupePrices keeps the last bar per ticker and date, an ON CONFLICT upsert
+// cannot touch the same row twice in one statement.
+func dedupePrices(prices []models.Price) []models.Price {
+	type key struct {
+		ticker string
+		date   string
+	}
+
+	positions := make(map[key]int, len(prices))
+	deduped := make([]models.Price, 0, len(prices))
+	for _, price := range prices {
+		k := key{ticker: price.Ticker, date: price.Date.Format("2006-01-02")}
+		if idx, ok := positions[k]; ok {
+			deduped[idx] = price
+			continue
+		}
+		positions[k] = len(deduped)
+		deduped = append(deduped, price)
+	}
+
+	return deduped
+}
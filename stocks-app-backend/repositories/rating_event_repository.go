@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"gorm.io/gorm"
+)
+
+type RatingEventRepository struct {
+	db *gorm.DB
+}
+
+func NewRatingEventRepository(db *gorm.DB) *RatingEventRepository {
+	return &RatingEventRepository{db: db}
+}
+
+// ListUntil returns the events observed up to and including until, oldest first.
+func (r *RatingEventRepository) ListUntil(ctx context.Context, until time.Time) ([]models.RatingEvent, error) {
+	var events []models.RatingEvent
+	err := r.db.WithContext(ctx).
+		Where("event_time <= ?", until).
+		Order("event_time ASC").
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *RatingEventRepository) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.RatingEvent{}).Count(&count).Error
+	return count, err
+}
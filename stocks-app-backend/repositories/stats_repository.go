@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"gorm.io/gorm"
+)
+
+type DBStats struct {
+	Stocks          int64      `json:"stocks"`
+	Tickers         int64      `json:"tickers"`
+	Brokerages      int64      `json:"brokerages"`
+	RatingEvents    int64      `json:"rating_events"`
+	Prices          int64      `json:"prices"`
+	PriceTickers    int64      `json:"price_tickers"`
+	LastStockUpdate *time.Time `json:"last_stock_update,omitempty"`
+	FirstPriceDate  *time.Time `json:"first_price_date,omitempty"`
+	LastPriceDate   *time.Time `json:"last_price_date,omitempty"`
+}
+
+type StatsRepository struct {
+	db *gorm.DB
+}
+
+func NewStatsRepository(db *gorm.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// Collect returns row counts and data freshness for every table.
+func (r *StatsRepository) Collect(ctx context.Context) (*DBStats, error) {
+	db := r.db.WithContext(ctx)
+	stats := &DBStats{}
+
+	var stocks struct {
+		Stocks     int64
+		Tickers    int64
+		Brokerages int64
+	}
+	err := db.Model(&models.Stock{}).
+		Select("COUNT(*) AS stocks, COUNT(DISTINCT ticker) AS tickers, COUNT(DISTINCT brokerage) AS brokerages").
+		Scan(&stocks).Error
+	if err != nil {
+		return nil, err
+	}
+	stats.Stocks = stocks.Stocks
+	stats.Tickers = stocks.Tickers
+	stats.Brokerages = stocks.Brokerages
+
+	if stats.LastStockUpdate, err = edgeTime(db.Model(&models.Stock{}), "updated_at", true); err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&models.RatingEvent{}).Count(&stats.RatingEvents).Error; err != nil {
+		return nil, err
+	}
+
+	var prices struct {
+		Prices       int64
+		PriceTickers int64
+	}
+	err = db.Model(&models.Price{}).
+		Select("COUNT(*) AS prices, COUNT(DISTINCT ticker) AS price_tickers").
+		Scan(&prices).Error
+	if err != nil {
+		return nil, err
+	}
+	stats.Prices = prices.Prices
+	stats.PriceTickers = prices.PriceTickers
+
+	if stats.FirstPriceDate, err = edgeTime(db.Model(&models.Price{}), "date", false); err != nil {
+		return nil, err
+	}
+	if stats.LastPriceDate, err = edgeTime(db.Model(&models.Price{}), "date", true); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// edgeTime returns the earliest or latest value of a time column, nil for an
+// empty table. It sorts instead of using MIN/MAX because SQLite returns
+// aggregates as plain text rather than as a time.
+func edgeTime(query *gorm.DB, column string, latest bool) (*time.Time, error) {
+	order := column + " ASC"
+	if latest {
+		order = column + " DESC"
+	}
+
+	var values []time.Time
+	if err := query.Order(order).Limit(1).Pluck(column, &values).Error; err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return &values[0], nil
+}
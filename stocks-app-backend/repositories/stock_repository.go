@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/metrics"
 	"github.com/felipepalacio293/stocks-app/models"
 	"gorm.io/gorm"
 )
@@ -24,30 +26,69 @@ func (r *StockRepository) Create(stock *models.Stock) error {
 	return r.db.Create(stock).Error
 }
 
-func (r *StockRepository) ListAll() ([]models.Stock, error) {
+func (r *StockRepository) ListAll(ctx context.Context) ([]models.Stock, error) {
 	var stocks []models.Stock
-	if err := r.db.Find(&stocks).Error; err != nil {
+	if err := r.db.WithContext(ctx).Find(&stocks).Error; err != nil {
 		return nil, err
 	}
 
 	return stocks, nil
 }
 
-func (r *StockRepository) List(page int, pageSize int, ticker string) ([]models.Stock, int64, error) {
-	var stocks []models.Stock
-	var count int64
+const (
+	SortUpsideAsc  = "upside"
+	SortUpsideDesc = "-upside"
+
+	// SQLite stores whole decimals as integers, * 1.0 keeps it from dividing
+	// them as integers
+	upsideSQL = "(stocks.target_to * 1.0 / NULLIF(lp.close, 0) - 1)"
+)
+
+type StockFilter struct {
+	Ticker    string
+	MinUpside *float64
+	Sort      string
+}
+
+// StockWithPrice is a stock row joined with the latest close for its ticker.
+// LastClose is nil when no price history has been loaded for the ticker.
+type StockWithPrice struct {
+	models.Stock
+	LastClose *float64
+}
 
-	query := r.db.Model(&models.Stock{})
+// Brokerages returns every brokerage with a stored rating.
+func (r *StockRepository) Brokerages(ctx context.Context) ([]string, error) {
+	var brokerages []string
+	if err := r.db.WithContext(ctx).Model(&models.Stock{}).Distinct().Pluck("brokerage", &brokerages).Error; err != nil {
+		return nil, err
+	}
+
+	return brokerages, nil
+}
 
-	if ticker != "" {
-		query = query.Where("ticker LIKE CONCAT('%', ?, '%')", ticker) // to prevent sql inyection
+func (r *StockRepository) ListAllWithPrices(ctx context.Context) ([]StockWithPrice, error) {
+	var stocks []StockWithPrice
+	query := r.filteredQuery(r.db.WithContext(ctx), StockFilter{}).Select("stocks.*, lp.close AS last_close")
+	if err := query.Find(&stocks).Error; err != nil {
+		return nil, err
 	}
 
+	return stocks, nil
+}
+
+func (r *StockRepository) List(ctx context.Context, page int, pageSize int, filter StockFilter) ([]StockWithPrice, int64, error) {
+	var stocks []StockWithPrice
+	var count int64
+
+	query := r.filteredQuery(r.db.WithContext(ctx), filter)
+
 	if err := query.Count(&count).Error; err != nil {
 		return nil, 0, err
 	}
 
 	offset := (page - 1) * pageSize
+	query = orderStocks(query.Select("stocks.*, lp.close AS last_close"), filter.Sort)
 	if err := query.Offset(offset).Limit(pageSize).Find(&stocks).Error; err != nil {
 		return nil, 0, err
 	}
@@ -55,13 +96,93 @@ func (r *StockRepository) List(page int, pageSize int, ticker string) ([]models.
 	return stocks, count, nil
 }
 
+// Each streams the stocks matching the filter to fn one row at a time, so
+// large exports never hold the whole table in memory.
+func (r *StockRepository) Each(ctx context.Context, filter StockFilter, fn func(StockWithPrice) error) error {
+	query := r.filteredQuery(r.db.WithContext(ctx), filter).Select("stocks.*, lp.close AS last_close")
+	if filter.Sort == "" {
+		query = query.Order("stocks.ticker, stocks.brokerage")
+	}
+
+	rows, err := orderStocks(query, filter.Sort).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stock StockWithPrice
+		if err := r.db.ScanRows(rows, &stock); err != nil {
+			return err
+		}
+		if err := fn(stock); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *StockRepository) filteredQuery(db *gorm.DB, filter StockFilter) *gorm.DB {
+	query := db.Model(&models.Stock{}).
+		Joins("LEFT JOIN (?) AS lp ON lp.ticker = stocks.ticker", gorm.Expr(latestClosesSQL))
+
+	if filter.Ticker != "" {
+		// LIKE is case sensitive on postgres but not on SQLite, lower both sides
+		// so the drivers agree
+		query = query.Where(`LOWER(stocks.ticker) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(filter.Ticker))+"%")
+	}
+
+	if filter.MinUpside != nil {
+		query = query.Where(upsideSQL+" >= ?", *filter.MinUpside)
+	}
+
+	return query
+}
+
+// likeEscaper makes LIKE wildcards in user input match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func orderStocks(query *gorm.DB, sort string) *gorm.DB {
+	switch sort {
+	case SortUpsideAsc:
+		return query.Order(upsideSQL + " ASC NULLS LAST")
+	case SortUpsideDesc:
+		return query.Order(upsideSQL + " DESC NULLS LAST")
+	}
+	return query
+}
+
 func (r *StockRepository) Update(stock *models.Stock) error {
 	return r.db.Save(stock).Error
 }
 
-func (r *StockRepository) BatchInsert(ctx context.Context, stocks []models.Stock, batchSize int) error {
+type StockChangeType string
+
+const (
+	StockCreated StockChangeType = "created"
+	StockUpdated StockChangeType = "updated"
+)
+
+type StockChange struct {
+	Type  StockChangeType
+	Stock models.Stock
+}
+
+type BatchResult struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Changes   []StockChange
+}
+
+// BatchInsert upserts stocks by source, ticker and brokerage. The returned result only
+// covers batches that were committed, so it is still meaningful when an error
+// is returned part way through.
+func (r *StockRepository) BatchInsert(ctx context.Context, stocks []models.Stock, batchSize int) (*BatchResult, error) {
+	result := &BatchResult{}
 	if len(stocks) == 0 {
-		return nil
+		return result, nil
 	}
 
 	if batchSize <= 0 {
@@ -74,38 +195,91 @@ func (r *StockRepository) BatchInsert(ctx context.Context, stocks []models.Stock
 			end = len(stocks)
 		}
 
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("stopped before batch %d-%d: %w", i, end, err)
+		}
+
 		batch := stocks[i:end]
+		var batchResult BatchResult
+
+		// A batch that has started is committed even if ctx is cancelled meanwhile,
+		// shutdown waits for it instead of rolling it back half way
+		txCtx := context.WithoutCancel(ctx)
 
-		err := r.withRetry(ctx, func(tx *gorm.DB) error {
+		err := r.withRetry(txCtx, func(tx *gorm.DB) error {
+			batchResult = BatchResult{}
+			now := time.Now()
 			for _, stock := range batch {
 				var existingStock models.Stock
-				result := tx.WithContext(ctx).Where("ticker = ? AND brokerage = ?",
-					stock.Ticker, stock.Brokerage).First(&existingStock)
+				result := tx.WithContext(txCtx).Where("source = ? AND ticker = ? AND brokerage = ?",
+					stock.Source, stock.Ticker, stock.Brokerage).First(&existingStock)
 
 				if result.Error != nil {
 					if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-						if err := tx.WithContext(ctx).Create(&stock).Error; err != nil {
+						if err := tx.WithContext(txCtx).Create(&stock).Error; err != nil {
 							return err
 						}
+						event := models.NewRatingEvent(stock, eventTime(stock, now))
+						if err := tx.WithContext(txCtx).Create(&event).Error; err != nil {
+							return err
+						}
+						batchResult.Inserted++
+						batchResult.Changes = append(batchResult.Changes, StockChange{Type: StockCreated, Stock: stock})
 					} else {
 						return result.Error
 					}
 				} else {
+					// Unchanged rows are left alone, their updated_at stays the
+					// time of the rating they hold
+					if !stockChanged(existingStock, stock) {
+						batchResult.Unchanged++
+						continue
+					}
 					stock.ID = existingStock.ID
-					if err := tx.WithContext(ctx).Save(&stock).Error; err != nil {
+					stock.CreatedAt = existingStock.CreatedAt
+					if err := tx.WithContext(txCtx).Save(&stock).Error; err != nil {
 						return err
 					}
+					event := models.NewRatingEvent(stock, eventTime(stock, now))
+					if err := tx.WithContext(txCtx).Create(&event).Error; err != nil {
+						return err
+					}
+					batchResult.Updated++
+					batchResult.Changes = append(batchResult.Changes, StockChange{Type: StockUpdated, Stock: stock})
 				}
 			}
 			return nil
 		})
 
 		if err != nil {
-			return fmt.Errorf("error processing batch %d-%d: %w", i, end, err)
+			return result, fmt.Errorf("error processing batch %d-%d: %w", i, end, err)
 		}
+
+		result.Inserted += batchResult.Inserted
+		result.Updated += batchResult.Updated
+		result.Unchanged += batchResult.Unchanged
+		result.Changes = append(result.Changes, batchResult.Changes...)
 	}
 
-	return nil
+	return result, nil
+}
+
+// eventTime stamps a rating event with the time the source published the
+// rating, or with the sync time for sources that do not report it.
+func eventTime(stock models.Stock, now time.Time) time.Time {
+	if stock.RatedAt != nil {
+		return *stock.RatedAt
+	}
+	return now
+}
+
+func stockChanged(existing, incoming models.Stock) bool {
+	return existing.Company != incoming.Company ||
+		existing.Action != incoming.Action ||
+		existing.RatingFrom != incoming.RatingFrom ||
+		existing.RatingTo != incoming.RatingTo ||
+		existing.TargetFrom != incoming.TargetFrom ||
+		existing.TargetTo != incoming.TargetTo
 }
 
 func (r *StockRepository) withRetry(ctx context.Context, operation func(*gorm.DB) error) error {
@@ -127,6 +301,7 @@ func (r *StockRepository) withRetry(ctx context.Context, operation func(*gorm.DB
 		tx.Rollback()
 
 		if isCockroachTransientError(err) {
+			metrics.DBRetriesTotal.WithLabelValues(metrics.RetryErrorClass(err)).Inc()
 			backoffMs := 50 * (1 << uint(attempt))
 			jitter := rand.Intn(50)
 			sleepTime := time.Duration(backoffMs+jitter) * time.Millisecond
@@ -142,7 +317,7 @@ func (r *StockRepository) withRetry(ctx context.Context, operation func(*gorm.DB
 		return err
 	}
 
-	return fmt.Errorf("max retries exceeded: %w", err)
+	return apperrors.Unavailable("database is unavailable, try again later", fmt.Errorf("max retries exceeded: %w", err))
 }
 
 func isCockroachTransientError(err error) bool {
@@ -162,6 +337,7 @@ func isCockroachTransientError(err error) bool {
 		"connection refused",
 		"deadline exceeded",
 		"read-only transaction",
+		"database is locked", // SQLite busy timeout
 	}
 
 	for _, pattern := range transientErrors {
@@ -0,0 +1,370 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/felipepalacio293/stocks-app/migrations"
+	"github.com/felipepalacio293/stocks-app/models"
+	"gorm.io/gorm"
+)
+
+// forEachDB runs test against a migrated, empty in-memory SQLite database, and
+// against postgres or CockroachDB when TEST_DATABASE_URL is set. Every table
+// in that database is emptied, never point it at real data.
+func forEachDB(t *testing.T, test func(t *testing.T, db *gorm.DB)) {
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, openTestDB(t, &config.Config{DBDriver: config.DBDriverSQLite, DBPath: ":memory:"}))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		url := os.Getenv("TEST_DATABASE_URL")
+		if url == "" {
+			t.Skip("TEST_DATABASE_URL is not set")
+		}
+		db := openTestDB(t, &config.Config{DBDriver: config.DBDriverPostgres, DBURL: url})
+		for _, table := range []string{"rating_events", "stocks", "prices"} {
+			if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+				t.Fatalf("emptying %s: %v", table, err)
+			}
+		}
+		test(t, db)
+	})
+}
+
+func openTestDB(t *testing.T, cfg *config.Config) *gorm.DB {
+	t.Helper()
+
+	cfg.Environment = "production" // keeps gorm from logging every statement
+	cfg.DBConnectRetries = 1
+	db, err := config.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := migrations.NewMigrator(db, time.Minute)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	return db
+}
+
+func seedStocks(t *testing.T, db *gorm.DB, stocks []models.Stock, closes map[string]float64) {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := NewStockRepository(db).BatchInsert(ctx, stocks, 0); err != nil {
+		t.Fatalf("seeding stocks: %v", err)
+	}
+
+	prices := make([]models.Price, 0, len(closes))
+	for ticker, close := range closes {
+		prices = append(prices, models.Price{Ticker: ticker, Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Close: close})
+	}
+	if err := NewPriceRepository(db).Upsert(ctx, prices, 0); err != nil {
+		t.Fatalf("seeding prices: %v", err)
+	}
+}
+
+func tickers(stocks []StockWithPrice) []string {
+	names := make([]string, len(stocks))
+	for i, stock := range stocks {
+		names[i] = stock.Ticker
+	}
+	return names
+}
+
+func assertTickers(t *testing.T, got []StockWithPrice, want ...string) {
+	t.Helper()
+
+	names := tickers(got)
+	if len(names) != len(want) {
+		t.Fatalf("got tickers %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got tickers %v, want %v", names, want)
+		}
+	}
+}
+
+func TestStockRepositoryList(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *gorm.DB) {
+		seedStocks(t, db, []models.Stock{
+			{Ticker: "AAPL", Brokerage: "Barclays", TargetTo: 200},
+			{Ticker: "MSFT", Brokerage: "Barclays", TargetTo: 220},
+			{Ticker: "TSLA", Brokerage: "Barclays", TargetTo: 300},
+			{Ticker: "BRK_B", Brokerage: "Barclays", TargetTo: 500},
+			{Ticker: "BRKXB", Brokerage: "Barclays", TargetTo: 500},
+		}, map[string]float64{"AAPL": 100, "MSFT": 200, "BRK_B": 400, "BRKXB": 1000})
+
+		repo := NewStockRepository(db)
+		ctx := context.Background()
+
+		t.Run("ticker filter", func(t *testing.T) {
+			cases := []struct {
+				ticker string
+				want   []string
+			}{
+				{"aap", []string{"AAPL"}},
+				{"Msf", []string{"MSFT"}},
+				{"K_B", []string{"BRK_B"}},
+				{"%", nil},
+				{`\`, nil},
+			}
+			for _, tc := range cases {
+				stocks, count, err := repo.List(ctx, 1, 10, StockFilter{Ticker: tc.ticker})
+				if err != nil {
+					t.Fatalf("List(%q): %v", tc.ticker, err)
+				}
+				if count != int64(len(tc.want)) {
+					t.Errorf("List(%q) counted %d stocks, want %d", tc.ticker, count, len(tc.want))
+				}
+				assertTickers(t, stocks, tc.want...)
+			}
+		})
+
+		t.Run("min upside", func(t *testing.T) {
+			minUpside := 0.2
+			stocks, count, err := repo.List(ctx, 1, 10, StockFilter{MinUpside: &minUpside})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if count != 2 {
+				t.Errorf("counted %d stocks, want 2", count)
+			}
+			if len(stocks) != 2 {
+				t.Fatalf("got tickers %v, want AAPL and BRK_B", tickers(stocks))
+			}
+		})
+
+		t.Run("sort by upside with missing prices last", func(t *testing.T) {
+			stocks, _, err := repo.List(ctx, 1, 10, StockFilter{Sort: SortUpsideDesc})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			assertTickers(t, stocks, "AAPL", "BRK_B", "MSFT", "BRKXB", "TSLA")
+			if stocks[0].LastClose == nil || *stocks[0].LastClose != 100 {
+				t.Errorf("AAPL last close = %v, want 100", stocks[0].LastClose)
+			}
+			if stocks[4].LastClose != nil {
+				t.Errorf("TSLA last close = %v, want none", *stocks[4].LastClose)
+			}
+
+			stocks, _, err = repo.List(ctx, 1, 10, StockFilter{Sort: SortUpsideAsc})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			assertTickers(t, stocks, "BRKXB", "MSFT", "BRK_B", "AAPL", "TSLA")
+		})
+
+		t.Run("pages", func(t *testing.T) {
+			stocks, count, err := repo.List(ctx, 3, 2, StockFilter{Sort: SortUpsideDesc})
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if count != 5 {
+				t.Errorf("counted %d stocks, want 5", count)
+			}
+			assertTickers(t, stocks, "TSLA")
+		})
+	})
+}
+
+func TestStockRepositoryBatchInsert(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *gorm.DB) {
+		repo := NewStockRepository(db)
+		events := NewRatingEventRepository(db)
+		ctx := context.Background()
+
+		stocks := []models.Stock{
+			{Source: "api", Ticker: "AAPL", Brokerage: "Barclays", Action: "upgraded by", RatingTo: "Buy", TargetTo: 200},
+			{Source: "api", Ticker: "AAPL", Brokerage: "Mizuho", Action: "reiterated by", RatingTo: "Hold", TargetTo: 180},
+			{Source: "api", Ticker: "MSFT", Brokerage: "Barclays", Action: "initiated by", RatingTo: "Buy", TargetTo: 500},
+		}
+
+		// Batches of two make the three stocks span two transactions
+		result, err := repo.BatchInsert(ctx, stocks, 2)
+		if err != nil {
+			t.Fatalf("first BatchInsert: %v", err)
+		}
+		if result.Inserted != 3 || result.Updated != 0 || result.Unchanged != 0 {
+			t.Fatalf("first BatchInsert = %+v, want 3 inserted", result)
+		}
+		for _, change := range result.Changes {
+			if change.Type != StockCreated {
+				t.Errorf("change for %s is %s, want %s", change.Stock.Ticker, change.Type, StockCreated)
+			}
+		}
+		assertEventCount(t, events, 3)
+
+		before, err := repo.ListAll(ctx)
+		if err != nil {
+			t.Fatalf("ListAll: %v", err)
+		}
+
+		ratedAt := time.Date(2026, 1, 14, 0, 30, 5, 0, time.UTC)
+		stocks[1].TargetTo = 190
+		stocks[1].RatedAt = &ratedAt
+		result, err = repo.BatchInsert(ctx, stocks, 2)
+		if err != nil {
+			t.Fatalf("second BatchInsert: %v", err)
+		}
+		if result.Inserted != 0 || result.Updated != 1 || result.Unchanged != 2 {
+			t.Fatalf("second BatchInsert = %+v, want 1 updated and 2 unchanged", result)
+		}
+		if len(result.Changes) != 1 || result.Changes[0].Type != StockUpdated || result.Changes[0].Stock.TargetTo != 190 {
+			t.Fatalf("second BatchInsert changes = %+v, want the Mizuho update", result.Changes)
+		}
+		// Unchanged rows keep their history as it was
+		assertEventCount(t, events, 4)
+
+		after, err := repo.ListAll(ctx)
+		if err != nil {
+			t.Fatalf("ListAll: %v", err)
+		}
+		for _, stock := range after {
+			for _, old := range before {
+				if stock.ID == old.ID && stock.Brokerage != "Mizuho" && !stock.UpdatedAt.Equal(old.UpdatedAt) {
+					t.Errorf("unchanged %s %s updated_at moved from %v to %v", stock.Ticker, stock.Brokerage, old.UpdatedAt, stock.UpdatedAt)
+				}
+			}
+		}
+
+		// The update is dated when the source published it, not when it was synced
+		history, err := events.ListUntil(ctx, ratedAt)
+		if err != nil {
+			t.Fatalf("ListUntil: %v", err)
+		}
+		if len(history) != 1 || history[0].Brokerage != "Mizuho" || !history[0].EventTime.Equal(ratedAt) {
+			t.Fatalf("events until %v = %+v, want only the Mizuho update", ratedAt, history)
+		}
+
+		// The same rating from another source is a row of its own
+		vendor := stocks[0]
+		vendor.Source = "vendor"
+		vendor.TargetTo = 210
+		result, err = repo.BatchInsert(ctx, []models.Stock{vendor}, 0)
+		if err != nil {
+			t.Fatalf("vendor BatchInsert: %v", err)
+		}
+		if result.Inserted != 1 {
+			t.Fatalf("vendor BatchInsert = %+v, want 1 inserted", result)
+		}
+		assertEventCount(t, events, 5)
+
+		all, err := repo.ListAll(ctx)
+		if err != nil {
+			t.Fatalf("ListAll: %v", err)
+		}
+		if len(all) != 4 {
+			t.Fatalf("got %d stocks, want 4", len(all))
+		}
+		for _, stock := range all {
+			if stock.Ticker == "AAPL" && stock.Brokerage == "Barclays" {
+				want := map[string]float64{"api": 200, "vendor": 210}[stock.Source]
+				if stock.TargetTo != want {
+					t.Errorf("%s AAPL target = %v, want %v", stock.Source, stock.TargetTo, want)
+				}
+			}
+		}
+	})
+}
+
+func assertEventCount(t *testing.T, events *RatingEventRepository, want int64) {
+	t.Helper()
+
+	count, err := events.Count(context.Background())
+	if err != nil {
+		t.Fatalf("counting rating events: %v", err)
+	}
+	if count != want {
+		t.Fatalf("got %d rating events, want %d", count, want)
+	}
+}
+
+func TestStockRepositoryBrokerages(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *gorm.DB) {
+		seedStocks(t, db, []models.Stock{
+			{Ticker: "MSFT", Brokerage: "Mizuho", TargetTo: 220},
+			{Ticker: "AAPL", Brokerage: "Mizuho", TargetTo: 200},
+			{Ticker: "AAPL", Brokerage: "Barclays", TargetTo: 210},
+		}, nil)
+
+		brokerages, err := NewStockRepository(db).Brokerages(context.Background())
+		if err != nil {
+			t.Fatalf("Brokerages: %v", err)
+		}
+		slices.Sort(brokerages)
+		if want := []string{"Barclays", "Mizuho"}; !slices.Equal(brokerages, want) {
+			t.Errorf("brokerages = %v, want %v", brokerages, want)
+		}
+	})
+}
+
+func TestStockRepositoryEach(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *gorm.DB) {
+		seedStocks(t, db, []models.Stock{
+			{Ticker: "MSFT", Brokerage: "Mizuho", TargetTo: 220},
+			{Ticker: "AAPL", Brokerage: "Mizuho", TargetTo: 200},
+			{Ticker: "AAPL", Brokerage: "Barclays", TargetTo: 210},
+		}, map[string]float64{"AAPL": 100})
+
+		repo := NewStockRepository(db)
+		ctx := context.Background()
+
+		var got []StockWithPrice
+		err := repo.Each(ctx, StockFilter{}, func(stock StockWithPrice) error {
+			got = append(got, stock)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Each: %v", err)
+		}
+		// Ordered by ticker and brokerage when no sort is given
+		assertTickers(t, got, "AAPL", "AAPL", "MSFT")
+		if got[0].Brokerage != "Barclays" || got[1].Brokerage != "Mizuho" {
+			t.Errorf("AAPL brokerages in order %s, %s, want Barclays, Mizuho", got[0].Brokerage, got[1].Brokerage)
+		}
+		if got[0].LastClose == nil || *got[0].LastClose != 100 {
+			t.Errorf("AAPL last close = %v, want 100", got[0].LastClose)
+		}
+
+		got = nil
+		err = repo.Each(ctx, StockFilter{Ticker: "msft"}, func(stock StockWithPrice) error {
+			got = append(got, stock)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Each: %v", err)
+		}
+		assertTickers(t, got, "MSFT")
+
+		stop := errors.New("stop")
+		calls := 0
+		err = repo.Each(ctx, StockFilter{}, func(StockWithPrice) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Fatalf("Each returned %v, want the callback error", err)
+		}
+		if calls != 1 {
+			t.Errorf("callback ran %d times after failing, want 1", calls)
+		}
+	})
+}
@@ -1,60 +1,112 @@
 package routes
 
 import (
+	"log/slog"
+
+	"github.com/felipepalacio293/stocks-app/cache"
 	"github.com/felipepalacio293/stocks-app/config"
 	"github.com/felipepalacio293/stocks-app/controllers"
+	"github.com/felipepalacio293/stocks-app/docs"
+	"github.com/felipepalacio293/stocks-app/health"
+	middlewares "github.com/felipepalacio293/stocks-app/middleware"
 	"github.com/felipepalacio293/stocks-app/repositories"
 	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/felipepalacio293/stocks-app/tasks"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"gorm.io/gorm"
 )
 
-func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
+func SetupRouter(db *gorm.DB, cfg *config.Config, eventHub *services.StockEventHub, responseCache *cache.ResponseCache, checker *health.Checker, syncTask *tasks.StockSyncTask) *gin.Engine {
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	r := gin.New()
+	// LoadConfig already validated the list, nil trusts no proxy at all
+	_ = r.SetTrustedProxies(cfg.TrustedProxies)
 
 	r.Use(gin.Recovery())
-
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", cfg.AllowedOrigins[0])
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
+	r.Use(middlewares.TracingMiddleware())
+	r.Use(middlewares.RequestIDMiddleware())
+	r.Use(middlewares.LoggerMiddleware(cfg))
+	r.Use(middlewares.MetricsMiddleware())
+	r.Use(middlewares.CORSMiddleware(cfg))
+	r.Use(middlewares.ErrorMiddleware(cfg))
 
 	stockRepo := repositories.NewStockRepository(db)
 	stockService := services.NewStockService(stockRepo, cfg)
 	stockController := controllers.NewStockController(stockService)
+	stockStreamController := controllers.NewStockStreamController(eventHub)
+	importService := services.NewStockImportService(stockRepo, eventHub, responseCache)
+	adminController := controllers.NewAdminController(importService)
+	backtestService := services.NewBacktestService(
+		stockRepo,
+		repositories.NewRatingEventRepository(db),
+		repositories.NewPriceRepository(db),
+		cfg.BacktestMaxDays,
+	)
+	backtestController := controllers.NewBacktestController(backtestService)
+	healthController := controllers.NewHealthController(checker)
+	syncController := controllers.NewSyncController(syncTask)
 
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"version": "1.0.0",
-		})
-	})
+	// /health is kept for existing monitors, it behaves like /livez
+	r.GET("/health", healthController.Livez)
+	r.GET("/livez", healthController.Livez)
+	r.GET("/readyz", healthController.Readyz)
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	docsController := controllers.NewDocsController()
+	r.GET("/openapi.json", docsController.OpenAPI)
+	r.GET("/docs", docsController.UI)
+
+	cached := middlewares.ResponseCacheMiddleware(responseCache, cfg.CacheMaxAge)
 
 	api := r.Group("/api/v1")
 	{
-		public := api.Group("/stocks")
+		public := api.Group("/stocks", middlewares.RateLimitMiddleware(cfg, "stocks"))
 		{
-			public.GET("", stockController.ListStocks)
-			public.GET("/recommendations", stockController.GetStockRecommendations)
-			public.GET("/recommendations/action/:action", stockController.GetRecommendationsByAction)
-			public.GET("/recommendations/brokerage/:brokerage", stockController.GetRecommendationsByBrokerage)
-			public.GET("/recommendations/rating/:rating", stockController.GetRecommendationsByRating)
+			public.GET("", cached, stockController.ListStocks)
+			public.GET("/stream", stockStreamController.StreamStocks)
+			public.GET("/export", stockController.ExportStocks)
+
+			// Scoring reads the whole table, so these get a tighter limit of their own
+			recommendations := public.Group("/recommendations", middlewares.RateLimitMiddleware(cfg, "recommendations"))
+			{
+				recommendations.GET("", cached, stockController.GetStockRecommendations)
+				recommendations.GET("/export", stockController.ExportRecommendations)
+				recommendations.GET("/action/:action", cached, stockController.GetRecommendationsByAction)
+				recommendations.GET("/brokerage/:brokerage", cached, stockController.GetRecommendationsByBrokerage)
+				recommendations.GET("/rating/:rating", cached, stockController.GetRecommendationsByRating)
+			}
+		}
+
+		api.POST("/backtests", middlewares.RateLimitMiddleware(cfg, "backtests"), backtestController.RunBacktest)
+
+		// Limited before auth so guessing the admin key is throttled too
+		admin := api.Group("/admin", middlewares.IPRateLimitMiddleware(cfg, "admin"), middlewares.AdminAuthMiddleware(cfg))
+		{
+			admin.POST("/import", adminController.ImportStocks)
+			admin.GET("/sync/status", syncController.Status)
 		}
 	}
 
+	warnUndocumentedRoutes(r)
+
 	return r
 }
+
+// warnUndocumentedRoutes flags routes added without updating docs/openapi.json.
+func warnUndocumentedRoutes(r *gin.Engine) {
+	missing, err := docs.UndocumentedRoutes(r.Routes())
+	if err != nil {
+		slog.Error("Invalid OpenAPI document", slog.Any("error", err))
+		return
+	}
+
+	for _, route := range missing {
+		slog.Warn("Route is missing from the OpenAPI document", slog.String("route", route))
+	}
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/cache"
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/felipepalacio293/stocks-app/docs"
+	"github.com/felipepalacio293/stocks-app/health"
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/felipepalacio293/stocks-app/sources"
+	"github.com/felipepalacio293/stocks-app/tasks"
+	"github.com/gin-gonic/gin"
+)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Building the router never touches the database
+	cfg := &config.Config{Environment: "test"}
+	eventHub := services.NewStockEventHub(10)
+	responseCache := cache.NewResponseCache(10, time.Minute)
+	syncTask := tasks.NewStockSyncTask(repositories.NewStockRepository(nil), sources.NewRegistry(), eventHub, responseCache, nil)
+
+	r := SetupRouter(nil, cfg, eventHub, responseCache, health.NewChecker(), syncTask)
+
+	missing, err := docs.UndocumentedRoutes(r.Routes())
+	if err != nil {
+		t.Fatalf("UndocumentedRoutes: %v", err)
+	}
+	for _, route := range missing {
+		t.Errorf("%s is not in docs/openapi.json", route)
+	}
+}
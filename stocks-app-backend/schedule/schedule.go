@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	// Market time zones must resolve on images without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// MarketHours is the named schedule that follows the trading calendar.
+const MarketHours = "market-hours"
+
+// Schedule tells when a recurring task runs next.
+type Schedule interface {
+	// Next returns the first run strictly after after, or the zero time when
+	// there is none.
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// Parse reads a schedule spec, one of:
+//   - a Go duration ("30m"), running that long after the previous run
+//   - a five field cron expression ("*/10 * * * 1-5"), optionally prefixed
+//     with CRON_TZ=<zone>, or a descriptor such as @hourly or @daily
+//   - market-hours, following calendar
+func Parse(spec string, calendar *TradingCalendar) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	if spec == MarketHours {
+		if calendar == nil {
+			return nil, fmt.Errorf("%s needs a trading calendar", MarketHours)
+		}
+		return calendar, nil
+	}
+
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval <= 0 {
+			return nil, fmt.Errorf("interval %q must be positive", spec)
+		}
+		return Every(interval), nil
+	}
+
+	cronSchedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a duration, cron expression or %s: %w", spec, MarketHours, err)
+	}
+	return &cronSpec{spec: spec, schedule: cronSchedule}, nil
+}
+
+type interval time.Duration
+
+// Every runs a task every d.
+func Every(d time.Duration) Schedule {
+	return interval(d)
+}
+
+func (i interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+func (i interval) String() string {
+	return "every " + time.Duration(i).String()
+}
+
+type cronSpec struct {
+	spec     string
+	schedule cron.Schedule
+}
+
+func (c *cronSpec) Next(after time.Time) time.Time {
+	return c.schedule.Next(after)
+}
+
+func (c *cronSpec) String() string {
+	return c.spec
+}
@@ -0,0 +1,51 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	calendar, err := NewTradingCalendar("America/New_York", "09:30-16:00", nil, 5*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("NewTradingCalendar: %v", err)
+	}
+	// Monday 2026-03-02 18:07 UTC, 13:07 in New York
+	after := time.Date(2026, 3, 2, 18, 7, 0, 0, time.UTC)
+
+	cases := []struct {
+		spec     string
+		calendar *TradingCalendar
+		wantErr  bool
+		wantNext time.Time
+	}{
+		{"30m", nil, false, after.Add(30 * time.Minute)},
+		{" 1h ", nil, false, after.Add(time.Hour)},
+		{"0s", nil, true, time.Time{}},
+		{"-5m", nil, true, time.Time{}},
+		{"", nil, true, time.Time{}},
+		{"*/10 * * * 1-5", nil, false, time.Date(2026, 3, 2, 18, 10, 0, 0, time.UTC)},
+		{"@hourly", nil, false, time.Date(2026, 3, 2, 19, 0, 0, 0, time.UTC)},
+		{"CRON_TZ=America/New_York 0 8 * * *", nil, false, time.Date(2026, 3, 3, 13, 0, 0, 0, time.UTC)},
+		{"every now and then", nil, true, time.Time{}},
+		{MarketHours, calendar, false, after.Add(5 * time.Minute)},
+		{MarketHours, nil, true, time.Time{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.spec, func(t *testing.T) {
+			schedule, err := Parse(tc.spec, tc.calendar)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %v, want an error", tc.spec, schedule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tc.spec, err)
+			}
+			if got := schedule.Next(after); !got.Equal(tc.wantNext) {
+				t.Errorf("Next(%v) = %v, want %v", after, got, tc.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// TradingCalendar runs a task every inSession while the exchange is open and
+// every offSession otherwise, with a run at each open and close so the first
+// and last ratings of the session are never an off-session interval late.
+// Sessions are weekdays between open and close in the exchange time zone,
+// except holidays.
+type TradingCalendar struct {
+	location   *time.Location
+	open       clock
+	close      clock
+	holidays   map[string]bool
+	inSession  time.Duration
+	offSession time.Duration
+}
+
+type clock struct {
+	hour, minute int
+}
+
+func (c clock) on(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), c.hour, c.minute, 0, 0, day.Location())
+}
+
+func (c clock) String() string {
+	return fmt.Sprintf("%02d:%02d", c.hour, c.minute)
+}
+
+// NewTradingCalendar builds a calendar from a time zone name, the session
+// hours as "09:30-16:00" and holiday dates as YYYY-MM-DD.
+func NewTradingCalendar(timezone, hours string, holidays []string, inSession, offSession time.Duration) (*TradingCalendar, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q: %w", timezone, err)
+	}
+
+	openText, closeText, ok := strings.Cut(hours, "-")
+	if !ok {
+		return nil, fmt.Errorf("hours %q must look like 09:30-16:00", hours)
+	}
+	open, err := parseClock(openText)
+	if err != nil {
+		return nil, err
+	}
+	close, err := parseClock(closeText)
+	if err != nil {
+		return nil, err
+	}
+	if open.hour*60+open.minute >= close.hour*60+close.minute {
+		return nil, fmt.Errorf("hours %q must open before they close", hours)
+	}
+
+	if inSession <= 0 || offSession <= 0 {
+		return nil, fmt.Errorf("session intervals must be positive")
+	}
+
+	calendar := &TradingCalendar{
+		location:   location,
+		open:       open,
+		close:      close,
+		holidays:   make(map[string]bool, len(holidays)),
+		inSession:  inSession,
+		offSession: offSession,
+	}
+	for _, holiday := range holidays {
+		date, err := time.Parse(dateLayout, strings.TrimSpace(holiday))
+		if err != nil {
+			return nil, fmt.Errorf("holiday %q must be a YYYY-MM-DD date", holiday)
+		}
+		calendar.holidays[date.Format(dateLayout)] = true
+	}
+
+	return calendar, nil
+}
+
+func parseClock(value string) (clock, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return clock{}, fmt.Errorf("time of day %q must look like 09:30", value)
+	}
+	return clock{hour: parsed.Hour(), minute: parsed.Minute()}, nil
+}
+
+// InSession reports whether the exchange is open at t.
+func (c *TradingCalendar) InSession(t time.Time) bool {
+	local := t.In(c.location)
+	open, close, ok := c.session(local)
+	return ok && !local.Before(open) && local.Before(close)
+}
+
+func (c *TradingCalendar) Next(after time.Time) time.Time {
+	local := after.In(c.location)
+	if open, close, ok := c.session(local); ok && !local.Before(open) && local.Before(close) {
+		return earliest(after.Add(c.inSession), close)
+	}
+	return earliest(after.Add(c.offSession), c.nextOpen(local))
+}
+
+func (c *TradingCalendar) String() string {
+	return fmt.Sprintf("%s %s-%s %s, every %s in session and %s outside",
+		MarketHours, c.open, c.close, c.location, c.inSession, c.offSession)
+}
+
+// session returns the open and close on day, ok is false when the exchange
+// does not trade that day.
+func (c *TradingCalendar) session(day time.Time) (open, close time.Time, ok bool) {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || c.holidays[day.Format(dateLayout)] {
+		return time.Time{}, time.Time{}, false
+	}
+	return c.open.on(day), c.close.on(day), true
+}
+
+// nextOpen returns the first session open after local, or the zero time when
+// a whole year of holidays leaves none.
+func (c *TradingCalendar) nextOpen(local time.Time) time.Time {
+	for days := 0; days <= 366; days++ {
+		day := local.AddDate(0, 0, days)
+		if open, _, ok := c.session(day); ok && open.After(local) {
+			return open
+		}
+	}
+	return time.Time{}
+}
+
+func earliest(a, b time.Time) time.Time {
+	if b.IsZero() || a.Before(b) {
+		return a
+	}
+	return b
+}
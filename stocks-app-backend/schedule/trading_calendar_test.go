@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTradingCalendarNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("loading time zone: %v", err)
+	}
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, newYork)
+	}
+
+	calendar, err := NewTradingCalendar("America/New_York", "09:30-16:00", []string{"2026-07-03"}, 5*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("NewTradingCalendar: %v", err)
+	}
+	// A long off-session interval makes every run outside the session wait
+	// for the next open
+	openOnly, err := NewTradingCalendar("America/New_York", "09:30-16:00", []string{"2026-07-03"}, 5*time.Minute, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewTradingCalendar: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		calendar  *TradingCalendar
+		after     time.Time
+		want      time.Time
+		inSession bool
+	}{
+		{"in session", calendar, at(3, 2, 10, 0), at(3, 2, 10, 5), true},
+		{"last interval is cut at the close", calendar, at(3, 2, 15, 58), at(3, 2, 16, 0), true},
+		{"at the close", calendar, at(3, 2, 16, 0), at(3, 2, 17, 0), false},
+		{"overnight", calendar, at(3, 2, 23, 30), at(3, 3, 0, 30), false},
+		{"first interval is cut at the open", calendar, at(3, 3, 9, 0), at(3, 3, 9, 30), false},
+		{"at the open", calendar, at(3, 3, 9, 30), at(3, 3, 9, 35), true},
+		{"weekend", calendar, at(3, 7, 12, 0), at(3, 7, 13, 0), false},
+		{"holiday", calendar, at(7, 3, 9, 0), at(7, 3, 10, 0), false},
+		{"friday close to monday open", openOnly, at(2, 27, 16, 0), at(3, 2, 9, 30), false},
+		{"over the holiday weekend", openOnly, at(7, 2, 16, 0), at(7, 6, 9, 30), false},
+		// Clocks go forward on Sunday March 8, the open stays 09:30 local
+		{"over the daylight saving change", openOnly, at(3, 6, 16, 0), at(3, 9, 9, 30), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.calendar.Next(tc.after); !got.Equal(tc.want) {
+				t.Errorf("Next(%v) = %v, want %v", tc.after, got.In(newYork), tc.want)
+			}
+			if got := tc.calendar.InSession(tc.after); got != tc.inSession {
+				t.Errorf("InSession(%v) = %v, want %v", tc.after, got, tc.inSession)
+			}
+		})
+	}
+}
+
+func TestNewTradingCalendarRejectsInvalidSettings(t *testing.T) {
+	cases := []struct {
+		name       string
+		timezone   string
+		hours      string
+		holidays   []string
+		inSession  time.Duration
+		offSession time.Duration
+	}{
+		{"unknown time zone", "Mars/Olympus", "09:30-16:00", nil, time.Minute, time.Hour},
+		{"hours without a dash", "America/New_York", "09:30", nil, time.Minute, time.Hour},
+		{"closes before it opens", "America/New_York", "16:00-09:30", nil, time.Minute, time.Hour},
+		{"invalid time of day", "America/New_York", "9h-16h", nil, time.Minute, time.Hour},
+		{"invalid holiday", "America/New_York", "09:30-16:00", []string{"July 3"}, time.Minute, time.Hour},
+		{"zero interval", "America/New_York", "09:30-16:00", nil, 0, time.Hour},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewTradingCalendar(tc.timezone, tc.hours, tc.holidays, tc.inSession, tc.offSession); err == nil {
+				t.Error("NewTradingCalendar succeeded, want an error")
+			}
+		})
+	}
+}
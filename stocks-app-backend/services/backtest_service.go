@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/tracing"
+)
+
+const (
+	backtestDateLayout = "2006-01-02"
+
+	DefaultBacktestRebalanceDays = 7
+	DefaultBacktestHorizonDays   = 30
+	DefaultBacktestTopN          = 5
+	maxBacktestDays              = 365
+
+	// A close older than this is not considered a valid quote for the date
+	maxStalePriceDays = 7
+)
+
+type BacktestRequest struct {
+	StartDate     string `json:"start_date"`
+	EndDate       string `json:"end_date"`
+	RebalanceDays int    `json:"rebalance_days"`
+	HorizonDays   int    `json:"horizon_days"`
+	TopN          int    `json:"top_n"`
+	Profile       string `json:"profile"`
+
+	start time.Time
+	end   time.Time
+}
+
+// Normalize validates the request and fills in defaults.
+func (r *BacktestRequest) Normalize() error {
+	var err error
+
+	r.start, err = time.Parse(backtestDateLayout, r.StartDate)
+	if err != nil {
+		return apperrors.InvalidField("start_date", "start_date must be a date in YYYY-MM-DD format")
+	}
+
+	r.end, err = time.Parse(backtestDateLayout, r.EndDate)
+	if err != nil {
+		return apperrors.InvalidField("end_date", "end_date must be a date in YYYY-MM-DD format")
+	}
+
+	if r.end.Before(r.start) {
+		return apperrors.InvalidField("end_date", "end_date must not be before start_date")
+	}
+
+	if r.RebalanceDays == 0 {
+		r.RebalanceDays = DefaultBacktestRebalanceDays
+	}
+	if r.RebalanceDays < 1 || r.RebalanceDays > maxBacktestDays {
+		return apperrors.InvalidField("rebalance_days", fmt.Sprintf("rebalance_days must be between 1 and %d", maxBacktestDays))
+	}
+
+	if r.HorizonDays == 0 {
+		r.HorizonDays = DefaultBacktestHorizonDays
+	}
+	if r.HorizonDays < 1 || r.HorizonDays > maxBacktestDays {
+		return apperrors.InvalidField("horizon_days", fmt.Sprintf("horizon_days must be between 1 and %d", maxBacktestDays))
+	}
+
+	if r.TopN == 0 {
+		r.TopN = DefaultBacktestTopN
+	}
+	if r.TopN < 1 || r.TopN > 100 {
+		return apperrors.InvalidField("top_n", "top_n must be between 1 and 100")
+	}
+
+	if r.Profile == "" {
+		r.Profile = DefaultScoringProfileName
+	}
+	if _, err := GetScoringProfile(r.Profile); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type BacktestPick struct {
+	Ticker        string   `json:"ticker"`
+	Score         float64  `json:"score"`
+	EntryClose    float64  `json:"entry_close"`
+	ExitClose     *float64 `json:"exit_close,omitempty"`
+	ForwardReturn *float64 `json:"forward_return,omitempty"`
+}
+
+type BacktestPeriod struct {
+	Date            string         `json:"date"`
+	UniverseSize    int            `json:"universe_size"`
+	Picks           []BacktestPick `json:"picks"`
+	AverageReturn   *float64       `json:"average_return,omitempty"`
+	BenchmarkReturn *float64       `json:"benchmark_return,omitempty"`
+	HitRate         *float64       `json:"hit_rate,omitempty"`
+	Turnover        float64        `json:"turnover"`
+}
+
+type BacktestResult struct {
+	Request          BacktestRequest  `json:"request"`
+	Periods          []BacktestPeriod `json:"periods"`
+	EvaluatedPeriods int              `json:"evaluated_periods"`
+	EvaluatedPicks   int              `json:"evaluated_picks"`
+	HitRate          float64          `json:"hit_rate"`
+	AverageReturn    float64          `json:"average_return"`
+	BenchmarkReturn  float64          `json:"benchmark_return"`
+	ExcessReturn     float64          `json:"excess_return"`
+	AverageTurnover  float64          `json:"average_turnover"`
+	Warnings         []string         `json:"warnings,omitempty"`
+}
+
+type BacktestService struct {
+	stockRepo *repositories.StockRepository
+	eventRepo *repositories.RatingEventRepository
+	priceRepo *repositories.PriceRepository
+	// Bounds the periods replayed and the price history loaded by one run
+	maxSpanDays int
+}
+
+func NewBacktestService(stockRepo *repositories.StockRepository, eventRepo *repositories.RatingEventRepository, priceRepo *repositories.PriceRepository, maxSpanDays int) *BacktestService {
+	return &BacktestService{
+		stockRepo:   stockRepo,
+		eventRepo:   eventRepo,
+		priceRepo:   priceRepo,
+		maxSpanDays: maxSpanDays,
+	}
+}
+
+// checkSpan rejects a normalized request whose dates are further apart than
+// the configured maximum.
+func (s *BacktestService) checkSpan(req BacktestRequest) error {
+	if s.maxSpanDays > 0 && req.end.Sub(req.start) > time.Duration(s.maxSpanDays)*24*time.Hour {
+		return apperrors.InvalidField("end_date", fmt.Sprintf("end_date must be at most %d days after start_date", s.maxSpanDays))
+	}
+	return nil
+}
+
+// Run replays the rating events as of each rebalance date, picks the topN
+// tickers with recommendStocks and measures their forward return over the
+// horizon against an equal-weight portfolio of every priced ticker.
+func (s *BacktestService) Run(ctx context.Context, req BacktestRequest) (_ *BacktestResult, err error) {
+	ctx, span := tracer.Start(ctx, "BacktestService.Run")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	if err := req.Normalize(); err != nil {
+		return nil, err
+	}
+	if err := s.checkSpan(req); err != nil {
+		return nil, err
+	}
+
+	profile, _ := GetScoringProfile(req.Profile)
+	result := &BacktestResult{Request: req, Periods: []BacktestPeriod{}}
+
+	events, err := s.eventRepo.ListUntil(ctx, endOfDay(req.end))
+	if err != nil {
+		return nil, fmt.Errorf("error loading rating events: %w", err)
+	}
+
+	if len(events) == 0 {
+		events, err = s.currentStocksAsEvents(ctx, req.end)
+		if err != nil {
+			return nil, fmt.Errorf("error loading stocks: %w", err)
+		}
+		result.Warnings = append(result.Warnings,
+			"no rating history recorded yet, replaying current stock rows as of when they were rated")
+	}
+
+	prices, err := s.priceRepo.ListBetween(ctx,
+		req.start.AddDate(0, 0, -maxStalePriceDays),
+		req.end.AddDate(0, 0, req.HorizonDays))
+	if err != nil {
+		return nil, fmt.Errorf("error loading prices: %w", err)
+	}
+	if len(prices) == 0 {
+		result.Warnings = append(result.Warnings, "no price history loaded for the requested range")
+		return result, nil
+	}
+
+	series := newPriceSeries(prices)
+	state := make(map[string]models.Stock)
+	nextEvent := 0
+	var previousPicks map[string]bool
+	var hits, turnoverPeriods int
+	var totalReturn, totalBenchmark, totalTurnover float64
+
+	for date := req.start; !date.After(req.end); date = date.AddDate(0, 0, req.RebalanceDays) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		asOf := endOfDay(date)
+		for nextEvent < len(events) && !events[nextEvent].EventTime.After(asOf) {
+			stock := events[nextEvent].ToStock()
+			// Sources rate the same pair independently, like the stocks table
+			state[stock.Source+"\x00"+stock.Ticker+"\x00"+stock.Brokerage] = stock
+			nextEvent++
+		}
+
+		exitDate := date.AddDate(0, 0, req.HorizonDays)
+		universe := make([]repositories.StockWithPrice, 0, len(state))
+		benchmarkReturns := make(map[string]float64)
+
+		for _, stock := range state {
+			entry, entryDate, ok := series.closeOnOrBefore(stock.Ticker, date)
+			if !ok {
+				continue
+			}
+
+			entryClose := entry
+			universe = append(universe, repositories.StockWithPrice{Stock: stock, LastClose: &entryClose})
+
+			if exit, exitOn, ok := series.closeOnOrBefore(stock.Ticker, exitDate); ok && exitOn.After(entryDate) {
+				benchmarkReturns[stock.Ticker] = exit/entry - 1
+			}
+		}
+
+		period := BacktestPeriod{
+			Date:         date.Format(backtestDateLayout),
+			UniverseSize: len(benchmarkReturns),
+			Picks:        []BacktestPick{},
+		}
+
+		ranked := recommendStocksWithProfile(universe, len(universe), profile, asOf)
+		picks := make(map[string]bool, req.TopN)
+		var periodReturn float64
+		var periodEvaluated, periodHits int
+
+		for _, rec := range ranked {
+			if len(picks) == req.TopN {
+				break
+			}
+			// Several brokerages can cover the same ticker, hold it once
+			if picks[rec.Ticker] {
+				continue
+			}
+			picks[rec.Ticker] = true
+
+			pick := BacktestPick{Ticker: rec.Ticker, Score: rec.Score, EntryClose: *rec.LastClose}
+			if forwardReturn, ok := benchmarkReturns[rec.Ticker]; ok {
+				exitClose := pick.EntryClose * (1 + forwardReturn)
+				pick.ExitClose = &exitClose
+				pick.ForwardReturn = &forwardReturn
+
+				periodReturn += forwardReturn
+				periodEvaluated++
+				if forwardReturn > 0 {
+					periodHits++
+				}
+			}
+			period.Picks = append(period.Picks, pick)
+		}
+
+		if len(picks) > 0 {
+			if previousPicks == nil {
+				period.Turnover = 1
+			} else {
+				kept := 0
+				for ticker := range picks {
+					if previousPicks[ticker] {
+						kept++
+					}
+				}
+				period.Turnover = 1 - float64(kept)/float64(len(picks))
+				totalTurnover += period.Turnover
+				turnoverPeriods++
+			}
+			previousPicks = picks
+		}
+
+		if periodEvaluated > 0 && len(benchmarkReturns) > 0 {
+			averageReturn := periodReturn / float64(periodEvaluated)
+			hitRate := float64(periodHits) / float64(periodEvaluated)
+			benchmarkReturn := mean(benchmarkReturns)
+
+			period.AverageReturn = &averageReturn
+			period.HitRate = &hitRate
+			period.BenchmarkReturn = &benchmarkReturn
+
+			result.EvaluatedPeriods++
+			result.EvaluatedPicks += periodEvaluated
+			hits += periodHits
+			totalReturn += averageReturn
+			totalBenchmark += benchmarkReturn
+		}
+
+		result.Periods = append(result.Periods, period)
+	}
+
+	if result.EvaluatedPeriods > 0 {
+		result.HitRate = float64(hits) / float64(result.EvaluatedPicks)
+		result.AverageReturn = totalReturn / float64(result.EvaluatedPeriods)
+		result.BenchmarkReturn = totalBenchmark / float64(result.EvaluatedPeriods)
+		result.ExcessReturn = result.AverageReturn - result.BenchmarkReturn
+	} else {
+		result.Warnings = append(result.Warnings, "no period had both ratings and forward prices to evaluate")
+	}
+
+	if turnoverPeriods > 0 {
+		result.AverageTurnover = totalTurnover / float64(turnoverPeriods)
+	}
+
+	return result, nil
+}
+
+// currentStocksAsEvents is the fallback for databases synced before rating
+// events were recorded. It only knows the latest state of each row, so results
+// carry look-ahead bias for dates before a row's last update.
+func (s *BacktestService) currentStocksAsEvents(ctx context.Context, until time.Time) ([]models.RatingEvent, error) {
+	stocks, err := s.stockRepo.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.RatingEvent, 0, len(stocks))
+	for _, stock := range stocks {
+		ratedAt := stock.RatingTime()
+		if ratedAt.After(endOfDay(until)) {
+			continue
+		}
+		events = append(events, models.NewRatingEvent(stock, ratedAt))
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].EventTime.Before(events[j].EventTime)
+	})
+
+	return events, nil
+}
+
+type priceSeries map[string][]models.Price
+
+func newPriceSeries(prices []models.Price) priceSeries {
+	series := make(priceSeries)
+	for _, price := range prices {
+		series[price.Ticker] = append(series[price.Ticker], price)
+	}
+	for ticker := range series {
+		bars := series[ticker]
+		sort.Slice(bars, func(i, j int) bool {
+			return bars[i].Date.Before(bars[j].Date)
+		})
+	}
+	return series
+}
+
+// closeOnOrBefore returns the last close at or before date, as long as it is
+// not older than maxStalePriceDays.
+func (s priceSeries) closeOnOrBefore(ticker string, date time.Time) (float64, time.Time, bool) {
+	bars := s[ticker]
+	idx := sort.Search(len(bars), func(i int) bool {
+		return bars[i].Date.After(date)
+	}) - 1
+
+	if idx < 0 || bars[idx].Close <= 0 || date.Sub(bars[idx].Date) > maxStalePriceDays*24*time.Hour {
+		return 0, time.Time{}, false
+	}
+
+	return bars[idx].Close, bars[idx].Date, true
+}
+
+func endOfDay(date time.Time) time.Time {
+	return date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+func mean(values map[string]float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/repositories"
+)
+
+func TestBacktestServiceReplaysDatedRatings(t *testing.T) {
+	date := func(day int) time.Time {
+		return time.Date(2026, 1, day, 0, 0, 0, 0, time.UTC)
+	}
+	dated := func(stock models.Stock, ratedAt time.Time) models.Stock {
+		stock.Source = "swechallenge"
+		stock.RatedAt = &ratedAt
+		return stock
+	}
+
+	// TSLA is only rated after the first rebalance, a backtest that stamps
+	// ratings with the sync time would either pick it too early or not at all
+	stocks := []models.Stock{
+		dated(models.Stock{Ticker: "AAPL", Brokerage: "Barclays", Action: ActionUpgraded, RatingTo: "Buy", TargetFrom: 100, TargetTo: 120}, date(5)),
+		dated(models.Stock{Ticker: "MSFT", Brokerage: "Barclays", Action: ActionDowngraded, RatingTo: "Sell", TargetFrom: 100, TargetTo: 80}, date(5)),
+		dated(models.Stock{Ticker: "TSLA", Brokerage: "Mizuho", Action: ActionUpgraded, RatingTo: "Buy", TargetFrom: 100, TargetTo: 150}, date(20)),
+	}
+
+	closes := map[string][]float64{
+		"AAPL": {100, 110, 105},
+		"MSFT": {100, 90, 90},
+		"TSLA": {100, 100, 120},
+	}
+	var prices []models.Price
+	for ticker, series := range closes {
+		for i, close := range series {
+			prices = append(prices, models.Price{Ticker: ticker, Date: date(12 + 10*i), Close: close})
+		}
+	}
+
+	cases := []struct {
+		name string
+		// dropEvents empties the rating history, like a database synced
+		// before rating events were recorded
+		dropEvents bool
+	}{
+		{"rating events", false},
+		{"current rows", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := openTestDB(t)
+			ctx := context.Background()
+
+			stockRepo := repositories.NewStockRepository(db)
+			if _, err := stockRepo.BatchInsert(ctx, stocks, 0); err != nil {
+				t.Fatalf("BatchInsert: %v", err)
+			}
+			priceRepo := repositories.NewPriceRepository(db)
+			if err := priceRepo.Upsert(ctx, prices, 0); err != nil {
+				t.Fatalf("Upsert prices: %v", err)
+			}
+			if tc.dropEvents {
+				if err := db.Exec("DELETE FROM rating_events").Error; err != nil {
+					t.Fatalf("emptying rating_events: %v", err)
+				}
+			}
+
+			service := NewBacktestService(stockRepo, repositories.NewRatingEventRepository(db), priceRepo, 365)
+			result, err := service.Run(ctx, BacktestRequest{
+				StartDate:     "2026-01-12",
+				EndDate:       "2026-01-22",
+				RebalanceDays: 10,
+				HorizonDays:   10,
+				TopN:          1,
+			})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			want := []struct {
+				date     string
+				universe int
+				pick     string
+				forward  float64
+			}{
+				{"2026-01-12", 2, "AAPL", 0.10},
+				{"2026-01-22", 3, "TSLA", 0.20},
+			}
+			if len(result.Periods) != len(want) {
+				t.Fatalf("got %d periods, want %d: %+v", len(result.Periods), len(want), result.Periods)
+			}
+			for i, period := range result.Periods {
+				picks := make([]string, 0, len(period.Picks))
+				for _, pick := range period.Picks {
+					picks = append(picks, pick.Ticker)
+				}
+				if period.Date != want[i].date || period.UniverseSize != want[i].universe || !slices.Equal(picks, []string{want[i].pick}) {
+					t.Errorf("period %d = %s with %d tickers picking %v, want %s with %d picking %s",
+						i, period.Date, period.UniverseSize, picks, want[i].date, want[i].universe, want[i].pick)
+					continue
+				}
+				if got := period.Picks[0].ForwardReturn; got == nil || !closeTo(*got, want[i].forward) {
+					t.Errorf("period %d forward return = %v, want %v", i, got, want[i].forward)
+				}
+			}
+			if result.EvaluatedPeriods != 2 || !closeTo(result.AverageReturn, 0.15) {
+				t.Errorf("evaluated %d periods averaging %v, want 2 averaging 0.15", result.EvaluatedPeriods, result.AverageReturn)
+			}
+		})
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/felipepalacio293/stocks-app/repositories"
+)
+
+// BrokerageSet holds the brokerages with a stored rating, the values the
+// brokerage validator accepts. Names are compared case-insensitively.
+type BrokerageSet struct {
+	mu    sync.RWMutex
+	names map[string]bool
+}
+
+// KnownBrokerages is loaded at startup, reloaded after every sync so rows
+// stored by other instances show up, and extended by imports.
+var KnownBrokerages = NewBrokerageSet()
+
+func NewBrokerageSet() *BrokerageSet {
+	return &BrokerageSet{names: make(map[string]bool)}
+}
+
+// Load replaces the set with the brokerages stored in repo.
+func (s *BrokerageSet) Load(ctx context.Context, repo *repositories.StockRepository) error {
+	brokerages, err := repo.Brokerages(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(brokerages))
+	for _, name := range brokerages {
+		names[strings.ToLower(name)] = true
+	}
+
+	s.mu.Lock()
+	s.names = names
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *BrokerageSet) Add(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, name := range names {
+		s.names[strings.ToLower(name)] = true
+	}
+}
+
+func (s *BrokerageSet) Contains(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.names[strings.ToLower(name)]
+}
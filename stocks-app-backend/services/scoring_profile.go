@@ -0,0 +1,104 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+)
+
+const DefaultScoringProfileName = "default"
+
+// ScoringProfile holds the weights used by scoreStock. The default profile
+// keeps the original hardcoded weights and adds the upside to the last close,
+// so it only ranks like the original scoring for tickers without prices.
+type ScoringProfile struct {
+	Name                          string  `json:"name"`
+	TargetChangePercentMultiplier float64 `json:"target_change_percent_multiplier"`
+	TargetPriceLogMultiplier      float64 `json:"target_price_log_multiplier"`
+	UpsidePercentMultiplier       float64 `json:"upside_percent_multiplier"`
+	RatingMultiplier              float64 `json:"rating_multiplier"`
+
+	ActionUpgradedScore      float64 `json:"action_upgraded_score"`
+	ActionTargetRaisedScore  float64 `json:"action_target_raised_score"`
+	ActionReiteratedScore    float64 `json:"action_reiterated_score"`
+	ActionTargetLoweredScore float64 `json:"action_target_lowered_score"`
+	ActionDowngradedScore    float64 `json:"action_downgraded_score"`
+
+	RecentUpdateDaysThreshold           int     `json:"recent_update_days_threshold"`
+	RecentUpdateScore                   float64 `json:"recent_update_score"`
+	ModeratelyRecentUpdateDaysThreshold int     `json:"moderately_recent_update_days_threshold"`
+	ModeratelyRecentUpdateScore         float64 `json:"moderately_recent_update_score"`
+}
+
+var DefaultScoringProfile = ScoringProfile{
+	Name:                                DefaultScoringProfileName,
+	TargetChangePercentMultiplier:       TargetChangePercentMultiplier,
+	TargetPriceLogMultiplier:            TargetPriceLogMultiplier,
+	UpsidePercentMultiplier:             UpsidePercentMultiplier,
+	RatingMultiplier:                    1.0,
+	ActionUpgradedScore:                 ActionUpgradedScore,
+	ActionTargetRaisedScore:             ActionTargetRaisedScore,
+	ActionReiteratedScore:               ActionReiteratedScore,
+	ActionTargetLoweredScore:            ActionTargetLoweredScore,
+	ActionDowngradedScore:               ActionDowngradedScore,
+	RecentUpdateDaysThreshold:           RecentUpdateDaysThreshold,
+	RecentUpdateScore:                   RecentUpdateScore,
+	ModeratelyRecentUpdateDaysThreshold: ModeratelyRecentUpdateDaysThreshold,
+	ModeratelyRecentUpdateScore:         ModeratelyRecentUpdateScore,
+}
+
+var scoringProfiles = map[string]ScoringProfile{
+	DefaultScoringProfileName: DefaultScoringProfile,
+	// Prioriza el potencial frente al precio actual sobre el cambio de objetivo
+	"upside": withOverrides(DefaultScoringProfile, func(p *ScoringProfile) {
+		p.Name = "upside"
+		p.TargetChangePercentMultiplier = 0.5
+		p.TargetPriceLogMultiplier = 0
+		p.UpsidePercentMultiplier = 3.0
+	}),
+	// Prioriza revisiones recientes de objetivo y cambios de rating
+	"momentum": withOverrides(DefaultScoringProfile, func(p *ScoringProfile) {
+		p.Name = "momentum"
+		p.TargetChangePercentMultiplier = 3.0
+		p.TargetPriceLogMultiplier = 0
+		p.ActionUpgradedScore = 8.0
+		p.ActionDowngradedScore = -8.0
+		p.RecentUpdateScore = 5.0
+		p.ModeratelyRecentUpdateScore = 2.0
+	}),
+	// Se apoya sobre todo en el rating del analista
+	"consensus": withOverrides(DefaultScoringProfile, func(p *ScoringProfile) {
+		p.Name = "consensus"
+		p.TargetChangePercentMultiplier = 0.5
+		p.TargetPriceLogMultiplier = 0
+		p.RatingMultiplier = 4.0
+	}),
+}
+
+func withOverrides(base ScoringProfile, override func(*ScoringProfile)) ScoringProfile {
+	override(&base)
+	return base
+}
+
+func GetScoringProfile(name string) (ScoringProfile, error) {
+	if name == "" {
+		return DefaultScoringProfile, nil
+	}
+
+	profile, ok := scoringProfiles[name]
+	if !ok {
+		return ScoringProfile{}, apperrors.InvalidField("profile", fmt.Sprintf("unknown scoring profile %q, expected one of: %v", name, ScoringProfileNames()))
+	}
+
+	return profile, nil
+}
+
+func ScoringProfileNames() []string {
+	names := make([]string, 0, len(scoringProfiles))
+	for name := range scoringProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
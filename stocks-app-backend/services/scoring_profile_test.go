@@ -0,0 +1,135 @@
+package services
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/repositories"
+)
+
+// originalScore is the hardcoded scoring the default profile replaced, with
+// the clock passed in.
+func originalScore(stock models.Stock, now time.Time) float64 {
+	var score float64 = 0
+
+	if stock.TargetFrom > 0 {
+		score += (stock.TargetTo - stock.TargetFrom) / stock.TargetFrom * 100 * 2.0
+	}
+
+	score += RatingScores[stock.RatingTo]
+
+	switch stock.Action {
+	case "target raised by":
+		score += 3
+	case "upgraded by":
+		score += 5
+	case "reiterated by":
+		score += 1
+	case "target lowered by":
+		score -= 2
+	case "downgraded by":
+		score -= 4
+	}
+
+	if stock.TargetTo > 0 {
+		score += math.Log(stock.TargetTo) * 2.0
+	}
+
+	days := int(now.Sub(stock.UpdatedAt).Hours() / 24)
+	if days <= 7 {
+		score += 2
+	} else if days <= 30 {
+		score += 1
+	}
+
+	return score
+}
+
+func TestDefaultScoringProfileMatchesOriginalRanking(t *testing.T) {
+	asOf := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	stocks := []models.Stock{
+		{Ticker: "AAPL", Action: ActionTargetRaised, RatingTo: "Buy", TargetFrom: 200, TargetTo: 240, UpdatedAt: asOf.Add(-2 * day)},
+		{Ticker: "MSFT", Action: ActionUpgraded, RatingTo: "Overweight", TargetFrom: 500, TargetTo: 520, UpdatedAt: asOf.Add(-20 * day)},
+		{Ticker: "TSLA", Action: ActionDowngraded, RatingTo: "Sell", TargetFrom: 300, TargetTo: 200, UpdatedAt: asOf.Add(-day)},
+		{Ticker: "AKBA", Action: ActionReiterated, RatingTo: "Buy", TargetFrom: 4, TargetTo: 4, UpdatedAt: asOf.Add(-60 * day)},
+		{Ticker: "NVDA", Action: "initiated by", RatingTo: "Outperform", TargetTo: 180, UpdatedAt: asOf.Add(-10 * day)},
+		{Ticker: "INTC", Action: ActionTargetLowered, RatingTo: "Hold", TargetFrom: 30, TargetTo: 25, UpdatedAt: asOf.Add(-3 * day)},
+		{Ticker: "XYZ", Action: ActionReiterated, RatingTo: "Not Rated", UpdatedAt: asOf},
+	}
+
+	original := slices.Clone(stocks)
+	slices.SortStableFunc(original, func(a, b models.Stock) int {
+		return cmp.Compare(originalScore(b, asOf), originalScore(a, asOf))
+	})
+	var want []string
+	for _, stock := range original {
+		want = append(want, stock.Ticker)
+	}
+
+	withPrices := make([]repositories.StockWithPrice, len(stocks))
+	for i, stock := range stocks {
+		withPrices[i] = repositories.StockWithPrice{Stock: stock}
+	}
+
+	cases := []struct {
+		name   string
+		stocks []repositories.StockWithPrice
+		want   []string
+	}{
+		{"without prices", withPrices, want},
+		{"top three", withPrices, want[:3]},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recommendations := recommendStocksWithProfile(slices.Clone(tc.stocks), len(tc.want), DefaultScoringProfile, asOf)
+
+			var got []string
+			for _, recommendation := range recommendations {
+				got = append(got, recommendation.Ticker)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("ranking = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	for _, stock := range stocks {
+		got := scoreStockWithProfile(repositories.StockWithPrice{Stock: stock}, DefaultScoringProfile, asOf).Score
+		if want := originalScore(stock, asOf); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s score = %v, want the original %v", stock.Ticker, got, want)
+		}
+	}
+}
+
+func TestDefaultScoringProfileAddsUpside(t *testing.T) {
+	asOf := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	stock := models.Stock{Ticker: "AAPL", Action: ActionReiterated, RatingTo: "Buy", TargetFrom: 240, TargetTo: 240, UpdatedAt: asOf}
+
+	cases := []struct {
+		name      string
+		lastClose *float64
+		extra     float64
+	}{
+		{"no price", nil, 0},
+		{"below target", ptr(200.0), 20},
+		{"above target", ptr(300.0), -20},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := scoreStockWithProfile(repositories.StockWithPrice{Stock: stock, LastClose: tc.lastClose}, DefaultScoringProfile, asOf).Score
+			if want := originalScore(stock, asOf) + tc.extra; math.Abs(got-want) > 1e-9 {
+				t.Errorf("score = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/repositories"
+)
+
+const (
+	DefaultEventHistorySize     = 1000
+	subscriberChannelBufferSize = 64
+)
+
+type StockEvent struct {
+	ID    uint64                       `json:"id"`
+	Type  repositories.StockChangeType `json:"type"`
+	Time  time.Time                    `json:"time"`
+	Stock models.StockResponse         `json:"stock"`
+}
+
+// StockEventGap tells a resuming client that events after LastEventID are no
+// longer retained, either because the history moved past it or because it was
+// issued before this process started. Clients should reload the current state
+// and resume from ResumeID.
+type StockEventGap struct {
+	LastEventID uint64 `json:"last_event_id"`
+	ResumeID    uint64 `json:"resume_id"`
+}
+
+type StockEventFilter struct {
+	Ticker    string
+	Brokerage string
+	Action    string
+}
+
+func (f StockEventFilter) Matches(event StockEvent) bool {
+	if f.Ticker != "" && !strings.EqualFold(f.Ticker, event.Stock.Ticker) {
+		return false
+	}
+	if f.Brokerage != "" && !strings.EqualFold(f.Brokerage, event.Stock.Brokerage) {
+		return false
+	}
+	if f.Action != "" && !strings.EqualFold(f.Action, event.Stock.Action) {
+		return false
+	}
+	return true
+}
+
+type StockSubscription struct {
+	Events <-chan StockEvent
+
+	events chan StockEvent
+	filter StockEventFilter
+	hub    *StockEventHub
+}
+
+func (s *StockSubscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// StockEventHub fans out stock changes to in-process subscribers and keeps a
+// bounded history so reconnecting clients can resume from a Last-Event-ID.
+//
+// IDs are unix microseconds, bumped when several events share one, so they
+// keep increasing across restarts and an ID from an earlier process is never
+// mistaken for a newer event. Microseconds stay within the integers
+// JavaScript represents exactly.
+type StockEventHub struct {
+	mu     sync.Mutex
+	lastID uint64
+	// floor is the newest ID that may have been issued without being in
+	// history: the last evicted event, or the start of this process
+	floor       uint64
+	history     []StockEvent
+	historySize int
+	subscribers map[*StockSubscription]struct{}
+	closed      bool
+}
+
+func NewStockEventHub(historySize int) *StockEventHub {
+	if historySize <= 0 {
+		historySize = DefaultEventHistorySize
+	}
+
+	start := uint64(time.Now().UnixMicro())
+	return &StockEventHub{
+		lastID:      start,
+		floor:       start,
+		historySize: historySize,
+		subscribers: make(map[*StockSubscription]struct{}),
+	}
+}
+
+func (h *StockEventHub) Publish(changes []repositories.StockChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for _, change := range changes {
+		h.lastID = max(h.lastID+1, uint64(now.UnixMicro()))
+		event := StockEvent{
+			ID:    h.lastID,
+			Type:  change.Type,
+			Time:  now,
+			Stock: change.Stock.ToResponse(),
+		}
+
+		h.history = append(h.history, event)
+		if len(h.history) > h.historySize {
+			evicted := len(h.history) - h.historySize
+			h.floor = h.history[evicted-1].ID
+			h.history = h.history[evicted:]
+		}
+
+		for sub := range h.subscribers {
+			if !sub.filter.Matches(event) {
+				continue
+			}
+
+			select {
+			case sub.events <- event:
+			default:
+				// The client can reconnect with Last-Event-ID and replay from history
+				slog.Warn("Dropping slow stock event subscriber", slog.Uint64("event_id", event.ID))
+				h.removeLocked(sub)
+			}
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. When lastEventID is non-zero the
+// retained events after it are returned so the caller can send them before
+// reading from the subscription channel, preceded by gap when some of the
+// events after lastEventID are no longer retained or lastEventID is unknown.
+func (h *StockEventHub) Subscribe(filter StockEventFilter, lastEventID uint64) (*StockSubscription, []StockEvent, *StockEventGap) {
+	events := make(chan StockEvent, subscriberChannelBufferSize)
+	sub := &StockSubscription{
+		Events: events,
+		events: events,
+		filter: filter,
+		hub:    h,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var backlog []StockEvent
+	var gap *StockEventGap
+	if lastEventID > 0 {
+		switch {
+		case lastEventID < h.floor:
+			gap = &StockEventGap{LastEventID: lastEventID, ResumeID: h.floor}
+		case lastEventID > h.lastID:
+			// Issued by another replica or a clock that has since gone back
+			gap = &StockEventGap{LastEventID: lastEventID, ResumeID: h.lastID}
+		}
+
+		for _, event := range h.history {
+			if event.ID > lastEventID && filter.Matches(event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	if h.closed {
+		close(events)
+		return sub, backlog, gap
+	}
+
+	h.subscribers[sub] = struct{}{}
+	return sub, backlog, gap
+}
+
+// Close ends every subscription so long-lived streams return and the HTTP
+// server can finish shutting down. New subscriptions are closed immediately.
+func (h *StockEventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		h.removeLocked(sub)
+	}
+}
+
+func (h *StockEventHub) unsubscribe(sub *StockSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(sub)
+}
+
+func (h *StockEventHub) removeLocked(sub *StockSubscription) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.events)
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/repositories"
+)
+
+func publishTickers(hub *StockEventHub, tickers ...string) {
+	changes := make([]repositories.StockChange, len(tickers))
+	for i, ticker := range tickers {
+		changes[i] = repositories.StockChange{Type: repositories.StockCreated, Stock: models.Stock{Ticker: ticker}}
+	}
+	hub.Publish(changes)
+}
+
+func TestStockEventHubIDsIncreaseAcrossHubs(t *testing.T) {
+	before := NewStockEventHub(10)
+	publishTickers(before, "AAPL", "MSFT", "TSLA")
+	_, events, _ := before.Subscribe(StockEventFilter{}, 1)
+	for i := 1; i < len(events); i++ {
+		if events[i].ID <= events[i-1].ID {
+			t.Fatalf("event IDs %d then %d do not increase", events[i-1].ID, events[i].ID)
+		}
+	}
+
+	// A restart is a new hub, its IDs must start after the old ones
+	time.Sleep(time.Millisecond)
+	after := NewStockEventHub(10)
+	publishTickers(after, "NVDA")
+	_, restarted, _ := after.Subscribe(StockEventFilter{}, 1)
+	if restarted[0].ID <= events[len(events)-1].ID {
+		t.Fatalf("ID %d after a restart is not above %d from before it", restarted[0].ID, events[len(events)-1].ID)
+	}
+}
+
+func TestStockEventHubSubscribeResume(t *testing.T) {
+	previous := NewStockEventHub(10)
+	publishTickers(previous, "OLD")
+	_, old, _ := previous.Subscribe(StockEventFilter{}, 1)
+	time.Sleep(time.Millisecond)
+
+	hub := NewStockEventHub(3)
+	publishTickers(hub, "AAPL", "MSFT")
+	_, all, _ := hub.Subscribe(StockEventFilter{}, 1)
+	publishTickers(hub, "TSLA", "NVDA")
+	_, retained, _ := hub.Subscribe(StockEventFilter{}, 1)
+
+	cases := []struct {
+		name        string
+		lastEventID uint64
+		wantBacklog []string
+		wantGap     bool
+		wantResume  uint64
+	}{
+		{"within history", retained[0].ID, []string{"TSLA", "NVDA"}, false, 0},
+		{"last event evicted", all[0].ID, []string{"MSFT", "TSLA", "NVDA"}, false, 0},
+		{"missed an evicted event", all[0].ID - 1, []string{"MSFT", "TSLA", "NVDA"}, true, all[0].ID},
+		{"before restart", old[0].ID, []string{"MSFT", "TSLA", "NVDA"}, true, all[0].ID},
+		{"unknown future ID", retained[2].ID + 1000, nil, true, retained[2].ID},
+		{"fresh connection", 0, nil, false, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sub, backlog, gap := hub.Subscribe(StockEventFilter{}, tc.lastEventID)
+			defer sub.Close()
+
+			tickers := make([]string, len(backlog))
+			for i, event := range backlog {
+				tickers[i] = event.Stock.Ticker
+			}
+			if len(tickers) != len(tc.wantBacklog) {
+				t.Fatalf("backlog = %v, want %v", tickers, tc.wantBacklog)
+			}
+			for i := range tickers {
+				if tickers[i] != tc.wantBacklog[i] {
+					t.Fatalf("backlog = %v, want %v", tickers, tc.wantBacklog)
+				}
+			}
+
+			if (gap != nil) != tc.wantGap {
+				t.Fatalf("gap = %+v, want gap %v", gap, tc.wantGap)
+			}
+			if gap != nil && gap.ResumeID != tc.wantResume {
+				t.Errorf("resume ID = %d, want %d", gap.ResumeID, tc.wantResume)
+			}
+		})
+	}
+}
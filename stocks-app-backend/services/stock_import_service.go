@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+	"github.com/felipepalacio293/stocks-app/cache"
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	ImportFieldTicker     = "ticker"
+	ImportFieldCompany    = "company"
+	ImportFieldBrokerage  = "brokerage"
+	ImportFieldAction     = "action"
+	ImportFieldRatingFrom = "rating_from"
+	ImportFieldRatingTo   = "rating_to"
+	ImportFieldTargetFrom = "target_from"
+	ImportFieldTargetTo   = "target_to"
+
+	ImportRowCreated   = "created"
+	ImportRowUpdated   = "updated"
+	ImportRowUnchanged = "unchanged"
+	ImportRowValid     = "valid"
+	ImportRowInvalid   = "invalid"
+	// Valid rows that were not stored because the import failed part way
+	ImportRowFailed = "failed"
+
+	importBatchSize = 100
+
+	// ImportSourceName is the rating source of rows uploaded by hand
+	ImportSourceName = "import"
+)
+
+var importFields = []string{
+	ImportFieldTicker,
+	ImportFieldCompany,
+	ImportFieldBrokerage,
+	ImportFieldAction,
+	ImportFieldRatingFrom,
+	ImportFieldRatingTo,
+	ImportFieldTargetFrom,
+	ImportFieldTargetTo,
+}
+
+var requiredImportFields = []string{ImportFieldTicker, ImportFieldBrokerage, ImportFieldTargetFrom, ImportFieldTargetTo}
+
+// ColumnMapping maps a stock field (e.g. "ticker") to the CSV header that holds
+// it (e.g. "Symbol"). Fields that are not mapped are looked up by their own name.
+type ColumnMapping map[string]string
+
+type ImportOptions struct {
+	Mapping   ColumnMapping
+	Delimiter rune
+	DryRun    bool
+	// Rating source the rows are stored under, ImportSourceName by default
+	Source string
+}
+
+type ImportRowResult struct {
+	Row       int      `json:"row"`
+	Ticker    string   `json:"ticker,omitempty"`
+	Brokerage string   `json:"brokerage,omitempty"`
+	Status    string   `json:"status"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	TotalRows int               `json:"total_rows"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Invalid   int               `json:"invalid"`
+	Failed    int               `json:"failed"`
+	DryRun    bool              `json:"dry_run"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+type StockImportService struct {
+	repo     *repositories.StockRepository
+	eventHub *StockEventHub
+	cache    *cache.ResponseCache
+}
+
+func NewStockImportService(repo *repositories.StockRepository, eventHub *StockEventHub, responseCache *cache.ResponseCache) *StockImportService {
+	return &StockImportService{
+		repo:     repo,
+		eventHub: eventHub,
+		cache:    responseCache,
+	}
+}
+
+func (s *StockImportService) ImportCSV(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	report, stocks, err := ReadStocksCSV(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rowIndexes := make([]int, 0, len(stocks))
+	for i, row := range report.Rows {
+		if row.Status == ImportRowValid {
+			rowIndexes = append(rowIndexes, i)
+		}
+	}
+
+	if opts.DryRun || len(stocks) == 0 {
+		return report, nil
+	}
+
+	batchResult, err := s.repo.BatchInsert(ctx, stocks, importBatchSize)
+	if s.eventHub != nil && batchResult != nil {
+		s.eventHub.Publish(batchResult.Changes)
+	}
+	if batchResult != nil && len(batchResult.Changes) > 0 {
+		s.cache.Invalidate()
+		for _, change := range batchResult.Changes {
+			KnownBrokerages.Add(change.Stock.Brokerage)
+		}
+	}
+	if batchResult == nil {
+		batchResult = &repositories.BatchResult{}
+	}
+
+	changes := make(map[string]repositories.StockChangeType, len(batchResult.Changes))
+	for _, change := range batchResult.Changes {
+		changes[importKey(change.Stock)] = change.Type
+	}
+
+	// Batches commit in order, so the stored rows are the first ones
+	stored := batchResult.Inserted + batchResult.Updated + batchResult.Unchanged
+	for i, idx := range rowIndexes {
+		row := &report.Rows[idx]
+		if i >= stored {
+			row.Status = ImportRowFailed
+			report.Failed++
+			continue
+		}
+
+		switch changes[importKey(stocks[i])] {
+		case repositories.StockCreated:
+			row.Status = ImportRowCreated
+			report.Created++
+		case repositories.StockUpdated:
+			row.Status = ImportRowUpdated
+			report.Updated++
+		default:
+			row.Status = ImportRowUnchanged
+			report.Unchanged++
+		}
+	}
+
+	if err != nil {
+		message := fmt.Sprintf("import stopped after storing %d of %d rows (%d created, %d updated, %d unchanged), the other %d were not stored",
+			stored, len(stocks), report.Created, report.Updated, report.Unchanged, report.Failed)
+		return report, apperrors.Wrap(apperrors.From(err).Kind, message, fmt.Errorf("error storing imported stocks: %w", err))
+	}
+
+	return report, nil
+}
+
+// ReadStocksCSV parses a ratings CSV without storing it. Every data row is in
+// the report, as valid or with its errors, and the valid ones are returned as
+// stocks tagged with opts.Source.
+func ReadStocksCSV(r io.Reader, opts ImportOptions) (*ImportReport, []models.Stock, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, apperrors.New(apperrors.KindUnprocessable, "csv file is empty")
+		}
+		return nil, nil, apperrors.Wrap(apperrors.KindUnprocessable, "error reading csv header: "+err.Error(), err)
+	}
+
+	columns, err := resolveImportColumns(header, opts.Mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source := opts.Source
+	if source == "" {
+		source = ImportSourceName
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun, Rows: []ImportRowResult{}}
+	stocks := make([]models.Stock, 0)
+
+	// Row numbers are 1-based and count the header, matching what spreadsheets show
+	for rowNumber := 2; ; rowNumber++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		report.TotalRows++
+
+		if err != nil {
+			report.Invalid++
+			report.Rows = append(report.Rows, ImportRowResult{
+				Row:    rowNumber,
+				Status: ImportRowInvalid,
+				Errors: []string{err.Error()},
+			})
+			continue
+		}
+
+		stock, rowErrors := parseImportRecord(record, columns)
+		stock.Source = source
+		result := ImportRowResult{
+			Row:       rowNumber,
+			Ticker:    stock.Ticker,
+			Brokerage: stock.Brokerage,
+			Status:    ImportRowValid,
+		}
+
+		if len(rowErrors) > 0 {
+			result.Status = ImportRowInvalid
+			result.Errors = rowErrors
+			report.Invalid++
+		} else {
+			stocks = append(stocks, stock)
+		}
+
+		report.Rows = append(report.Rows, result)
+	}
+
+	return report, stocks, nil
+}
+
+func resolveImportColumns(header []string, mapping ColumnMapping) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for field := range mapping {
+		if !isImportField(field) {
+			return nil, apperrors.InvalidField("mapping", "unknown import field in column mapping: "+field)
+		}
+	}
+
+	columns := make(map[string]int, len(importFields))
+	for _, field := range importFields {
+		column := field
+		if mapped, ok := mapping[field]; ok && mapped != "" {
+			column = mapped
+		}
+
+		if idx, ok := positions[strings.ToLower(strings.TrimSpace(column))]; ok {
+			columns[field] = idx
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, field := range requiredImportFields {
+		if _, ok := columns[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, apperrors.New(apperrors.KindUnprocessable, "csv is missing required columns: "+strings.Join(missing, ", "))
+	}
+
+	return columns, nil
+}
+
+func parseImportRecord(record []string, columns map[string]int) (models.Stock, []string) {
+	value := func(field string) string {
+		idx, ok := columns[field]
+		if !ok || idx >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+
+	stock := models.Stock{
+		ID:         uuid.New(),
+		Ticker:     strings.ToUpper(value(ImportFieldTicker)),
+		Company:    value(ImportFieldCompany),
+		Brokerage:  value(ImportFieldBrokerage),
+		Action:     value(ImportFieldAction),
+		RatingFrom: value(ImportFieldRatingFrom),
+		RatingTo:   value(ImportFieldRatingTo),
+	}
+
+	rowErrors := make([]string, 0)
+
+	if stock.Ticker == "" {
+		rowErrors = append(rowErrors, "ticker is required")
+	} else if len(stock.Ticker) > 20 {
+		rowErrors = append(rowErrors, "ticker must be at most 20 characters")
+	}
+
+	if stock.Brokerage == "" {
+		rowErrors = append(rowErrors, "brokerage is required")
+	} else if len(stock.Brokerage) > 100 {
+		rowErrors = append(rowErrors, "brokerage must be at most 100 characters")
+	}
+
+	if len(stock.Company) > 255 {
+		rowErrors = append(rowErrors, "company must be at most 255 characters")
+	}
+
+	for _, field := range []string{ImportFieldAction, ImportFieldRatingFrom, ImportFieldRatingTo} {
+		if len(value(field)) > 50 {
+			rowErrors = append(rowErrors, fmt.Sprintf("%s must be at most 50 characters", field))
+		}
+	}
+
+	targetFrom, err := parseImportPrice(value(ImportFieldTargetFrom))
+	if err != nil {
+		rowErrors = append(rowErrors, fmt.Sprintf("target_from: %v", err))
+	}
+	stock.TargetFrom = targetFrom
+
+	targetTo, err := parseImportPrice(value(ImportFieldTargetTo))
+	if err != nil {
+		rowErrors = append(rowErrors, fmt.Sprintf("target_to: %v", err))
+	}
+	stock.TargetTo = targetTo
+
+	return stock, rowErrors
+}
+
+func parseImportPrice(value string) (float64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("value is required")
+	}
+
+	price, err := utils.ParsePrice(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price %q", value)
+	}
+
+	// decimal(10,2) column
+	if price < 0 || price >= 1e8 {
+		return 0, fmt.Errorf("price %q is out of range", value)
+	}
+
+	return price, nil
+}
+
+func isImportField(field string) bool {
+	for _, f := range importFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func importKey(stock models.Stock) string {
+	return stock.Source + "\x00" + stock.Ticker + "\x00" + stock.Brokerage
+}
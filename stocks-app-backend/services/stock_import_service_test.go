@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/cache"
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/felipepalacio293/stocks-app/migrations"
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := config.InitDB(&config.Config{DBDriver: config.DBDriverSQLite, DBPath: ":memory:", DBConnectRetries: 1, Environment: "production"})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := migrations.NewMigrator(db, time.Minute)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db
+}
+
+// failStockInsertsAfter makes every stock insert after the first n fail.
+func failStockInsertsAfter(t *testing.T, db *gorm.DB, n int) {
+	t.Helper()
+
+	inserts := 0
+	err := db.Callback().Create().Before("gorm:create").Register("test:fail_stock_inserts", func(tx *gorm.DB) {
+		if tx.Statement.Table != "stocks" {
+			return
+		}
+		if inserts++; inserts > n {
+			tx.AddError(errors.New("disk full"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("registering callback: %v", err)
+	}
+}
+
+func importCSV(rows int) string {
+	var b strings.Builder
+	b.WriteString("ticker,company,brokerage,action,rating_from,rating_to,target_from,target_to\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "T%03d,Company %d,Barclays,upgraded by,Hold,Buy,$10.00,$12.50\n", i, i)
+	}
+	// One row the parser rejects, it never reaches the database
+	b.WriteString("BAD,Bad Inc.,Barclays,upgraded by,Hold,Buy,$10.00,N/A\n")
+	return b.String()
+}
+
+func TestImportCSV(t *testing.T) {
+	cases := []struct {
+		name        string
+		rows        int
+		failAfter   int
+		dryRun      bool
+		wantCreated int
+		wantFailed  int
+		wantValid   int
+		wantErr     string
+	}{
+		{name: "all stored", rows: 150, failAfter: -1, wantCreated: 150},
+		{name: "dry run", rows: 150, failAfter: 0, dryRun: true, wantValid: 150},
+		// The first batch of importBatchSize commits, the second rolls back
+		{name: "fails in the second batch", rows: 250, failAfter: 150, wantCreated: importBatchSize, wantFailed: 150,
+			wantErr: "import stopped after storing 100 of 250 rows (100 created, 0 updated, 0 unchanged), the other 150 were not stored"},
+		{name: "fails in the first batch", rows: 50, failAfter: 10, wantFailed: 50,
+			wantErr: "import stopped after storing 0 of 50 rows"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := openTestDB(t)
+			if tc.failAfter >= 0 {
+				failStockInsertsAfter(t, db, tc.failAfter)
+			}
+			service := NewStockImportService(repositories.NewStockRepository(db), nil, cache.NewResponseCache(10, time.Minute))
+
+			report, err := service.ImportCSV(context.Background(), strings.NewReader(importCSV(tc.rows)), ImportOptions{DryRun: tc.dryRun})
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("ImportCSV: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("ImportCSV error = %v, want %q", err, tc.wantErr)
+			}
+			if report == nil {
+				t.Fatal("ImportCSV returned no report")
+			}
+
+			if report.TotalRows != tc.rows+1 || report.Invalid != 1 {
+				t.Errorf("report has %d rows and %d invalid, want %d and 1", report.TotalRows, report.Invalid, tc.rows+1)
+			}
+			if report.Created != tc.wantCreated || report.Failed != tc.wantFailed {
+				t.Errorf("report created %d and failed %d, want %d and %d", report.Created, report.Failed, tc.wantCreated, tc.wantFailed)
+			}
+
+			statuses := map[string]int{}
+			for _, row := range report.Rows {
+				statuses[row.Status]++
+			}
+			want := map[string]int{ImportRowInvalid: 1}
+			for status, count := range map[string]int{ImportRowCreated: tc.wantCreated, ImportRowFailed: tc.wantFailed, ImportRowValid: tc.wantValid} {
+				if count > 0 {
+					want[status] = count
+				}
+			}
+			if fmt.Sprint(statuses) != fmt.Sprint(want) {
+				t.Errorf("row statuses = %v, want %v", statuses, want)
+			}
+			// Stored rows come first, the rows after them are the failed ones
+			if tc.wantFailed > 0 && report.Rows[tc.wantCreated].Status != ImportRowFailed {
+				t.Errorf("row %d is %s, want %s", report.Rows[tc.wantCreated].Row, report.Rows[tc.wantCreated].Status, ImportRowFailed)
+			}
+
+			var stored int64
+			if err := db.Table("stocks").Count(&stored).Error; err != nil {
+				t.Fatalf("counting stocks: %v", err)
+			}
+			if int(stored) != tc.wantCreated {
+				t.Errorf("%d stocks stored, want %d", stored, tc.wantCreated)
+			}
+		})
+	}
+}
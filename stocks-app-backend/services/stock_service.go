@@ -1,23 +1,53 @@
 package services
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/felipepalacio293/stocks-app/apperrors"
 	"github.com/felipepalacio293/stocks-app/config"
 	"github.com/felipepalacio293/stocks-app/models"
 	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/tracing"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = tracing.Tracer("github.com/felipepalacio293/stocks-app/services")
+
 type StockRecommendation struct {
-	Ticker        string  `json:"ticker"`
-	Company       string  `json:"company"`
-	Score         float64 `json:"score"`
-	Rating        string  `json:"rating"`
-	TargetPrice   float64 `json:"target_price"`
-	Action        string  `json:"action"`
-	ChangePercent float64 `json:"change_percent"`
+	Ticker        string   `json:"ticker"`
+	Company       string   `json:"company"`
+	Score         float64  `json:"score"`
+	Rating        string   `json:"rating"`
+	TargetPrice   float64  `json:"target_price"`
+	Action        string   `json:"action"`
+	ChangePercent float64  `json:"change_percent"`
+	LastClose     *float64 `json:"last_close,omitempty"`
+	Upside        *float64 `json:"upside,omitempty"`
+}
+
+func (StockRecommendation) CSVHeader() []string {
+	return []string{"ticker", "company", "score", "rating", "target_price", "action", "change_percent", "last_close", "upside"}
+}
+
+func (r StockRecommendation) CSVRecord() []string {
+	return []string{
+		r.Ticker,
+		r.Company,
+		strconv.FormatFloat(r.Score, 'f', 4, 64),
+		r.Rating,
+		strconv.FormatFloat(r.TargetPrice, 'f', 2, 64),
+		r.Action,
+		strconv.FormatFloat(r.ChangePercent, 'f', 2, 64),
+		utils.FormatOptionalFloat(r.LastClose, 4),
+		utils.FormatOptionalFloat(r.Upside, 4),
+	}
 }
 
 type StockService struct {
@@ -29,6 +59,8 @@ const (
 	// Factores de la puntuación
 	TargetChangePercentMultiplier float64 = 2.0
 	TargetPriceLogMultiplier      float64 = 2.0
+	// Potencial al alza del precio objetivo frente al último cierre
+	UpsidePercentMultiplier float64 = 1.0
 
 	// Puntuaciones para las acciones
 	ActionUpgradedScore      float64 = 5.0
@@ -44,6 +76,27 @@ const (
 	ModeratelyRecentUpdateScore         float64 = 1.0
 )
 
+// Rating actions that scoreStockWithProfile scores
+const (
+	ActionUpgraded      = "upgraded by"
+	ActionTargetRaised  = "target raised by"
+	ActionReiterated    = "reiterated by"
+	ActionTargetLowered = "target lowered by"
+	ActionDowngraded    = "downgraded by"
+)
+
+var Actions = []string{ActionUpgraded, ActionTargetRaised, ActionReiterated, ActionTargetLowered, ActionDowngraded}
+
+// Actions the rating sources report that scoreStockWithProfile does not score
+const (
+	ActionInitiated = "initiated by"
+	ActionTargetSet = "target set by"
+)
+
+// KnownActions is every action the rating sources report, the values the
+// action validator accepts.
+var KnownActions = []string{ActionUpgraded, ActionTargetRaised, ActionReiterated, ActionTargetLowered, ActionDowngraded, ActionInitiated, ActionTargetSet}
+
 var RatingScores = map[string]float64{
 	"Buy":            5.0,
 	"Outperform":     4.0,
@@ -62,45 +115,111 @@ func NewStockService(repo *repositories.StockRepository, cfg *config.Config) *St
 	}
 }
 
-func (s *StockService) ListStocks(page int, pageSize int, ticker string) ([]models.StockResponse, int64, error) {
-	stocks, count, err := s.repo.List(page, pageSize, ticker)
+func (s *StockService) ListStocks(ctx context.Context, page int, pageSize int, filter repositories.StockFilter) ([]models.StockResponse, int64, error) {
+	ctx, span := tracer.Start(ctx, "StockService.ListStocks")
+	defer span.End()
+
+	stocks, count, err := s.repo.List(ctx, page, pageSize, filter)
 
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, 0, err
 	}
 
 	stockResponses := make([]models.StockResponse, len(stocks))
 	for i, stock := range stocks {
-		stockResponses[i] = stock.ToResponse()
+		stockResponses[i] = stock.ToResponse().WithLastClose(stock.LastClose)
 	}
 
 	return stockResponses, count, nil
 }
 
-func (s *StockService) GetAllStocks() ([]models.Stock, error) {
-	return s.repo.ListAll()
+func (s *StockService) ExportStocks(ctx context.Context, filter repositories.StockFilter, fn func(models.StockResponse) error) error {
+	ctx, span := tracer.Start(ctx, "StockService.ExportStocks")
+	defer span.End()
+
+	return s.repo.Each(ctx, filter, func(stock repositories.StockWithPrice) error {
+		return fn(stock.ToResponse().WithLastClose(stock.LastClose))
+	})
+}
+
+// ExportRecommendations scores rows as they are streamed from the database and
+// only keeps the lightweight recommendations around for ranking. A topN of zero
+// exports every scored row.
+func (s *StockService) ExportRecommendations(ctx context.Context, filter repositories.StockFilter, topN int) ([]StockRecommendation, error) {
+	ctx, span := tracer.Start(ctx, "StockService.ExportRecommendations")
+	defer span.End()
+
+	recommendations := make([]StockRecommendation, 0)
+
+	err := s.repo.Each(ctx, filter, func(stock repositories.StockWithPrice) error {
+		recommendations = append(recommendations, scoreStock(stock))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortRecommendations(recommendations)
+
+	if topN > 0 && len(recommendations) > topN {
+		recommendations = recommendations[:topN]
+	}
+
+	return recommendations, nil
+}
+
+func (s *StockService) GetAllStocks(ctx context.Context) ([]repositories.StockWithPrice, error) {
+	ctx, span := tracer.Start(ctx, "StockService.GetAllStocks")
+	defer span.End()
+
+	stocks, err := s.repo.ListAllWithPrices(ctx)
+	tracing.RecordError(span, err)
+	return stocks, err
 }
 
-func (s *StockService) GetStockRecommendations(topN int) ([]StockRecommendation, error) {
-	stocks, err := s.repo.ListAll()
+func (s *StockService) GetStockRecommendations(ctx context.Context, topN int, profileName string) ([]StockRecommendation, error) {
+	ctx, span := tracer.Start(ctx, "StockService.GetStockRecommendations")
+	defer span.End()
+
+	profile, err := GetScoringProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	stocks, err := s.repo.ListAllWithPrices(ctx)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, err
 	}
 
-	return recommendStocks(stocks, topN), nil
+	return scoreWithSpan(ctx, stocks, topN, profile), nil
 }
 
-func recommendStocks(stocks []models.Stock, topN int) []StockRecommendation {
+// scoreWithSpan ranks stocks inside its own span so scoring time can be told
+// apart from the database and serialization in a trace.
+func scoreWithSpan(ctx context.Context, stocks []repositories.StockWithPrice, topN int, profile ScoringProfile) []StockRecommendation {
+	_, span := tracer.Start(ctx, "StockService.score")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("stocks.count", len(stocks)),
+		attribute.Int("stocks.top_n", topN),
+		attribute.String("scoring.profile", profile.Name),
+	)
+
+	return recommendStocksWithProfile(stocks, topN, profile, time.Now())
+}
+
+func recommendStocksWithProfile(stocks []repositories.StockWithPrice, topN int, profile ScoringProfile, asOf time.Time) []StockRecommendation {
 	scoredStocks := make([]StockRecommendation, 0, len(stocks))
 
 	for _, stock := range stocks {
-		score := scoreStock(stock)
+		score := scoreStockWithProfile(stock, profile, asOf)
 		scoredStocks = append(scoredStocks, score)
 	}
 
-	sort.Slice(scoredStocks, func(i, j int) bool {
-		return scoredStocks[i].Score > scoredStocks[j].Score
-	})
+	sortRecommendations(scoredStocks)
 
 	if len(scoredStocks) < topN {
 		topN = len(scoredStocks)
@@ -109,8 +228,14 @@ func recommendStocks(stocks []models.Stock, topN int) []StockRecommendation {
 	return scoredStocks[:topN]
 }
 
-func (s *StockService) GetTopStocksByAction(stocks []models.Stock, action string, topN int) []StockRecommendation {
-	filtered := make([]models.Stock, 0)
+func sortRecommendations(recommendations []StockRecommendation) {
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+}
+
+func (s *StockService) GetTopStocksByAction(ctx context.Context, stocks []repositories.StockWithPrice, action string, topN int) ([]StockRecommendation, error) {
+	filtered := make([]repositories.StockWithPrice, 0)
 
 	for _, stock := range stocks {
 		if stock.Action == action {
@@ -118,89 +243,89 @@ func (s *StockService) GetTopStocksByAction(stocks []models.Stock, action string
 		}
 	}
 
-	return recommendStocks(filtered, topN)
+	return scoreWithSpan(ctx, filtered, topN, DefaultScoringProfile), nil
 }
 
-func (s *StockService) GetTopStocksByBrokerage(stocks []models.Stock, brokerage string, topN int) []StockRecommendation {
-	filtered := make([]models.Stock, 0)
+func (s *StockService) GetTopStocksByBrokerage(ctx context.Context, stocks []repositories.StockWithPrice, brokerage string, topN int) ([]StockRecommendation, error) {
+	filtered := make([]repositories.StockWithPrice, 0)
 
 	for _, stock := range stocks {
-		if stock.Brokerage == brokerage {
+		if strings.EqualFold(stock.Brokerage, brokerage) {
 			filtered = append(filtered, stock)
 		}
 	}
 
-	return recommendStocks(filtered, topN)
+	return scoreWithSpan(ctx, filtered, topN, DefaultScoringProfile), nil
 }
 
-func (s *StockService) GetTopStocksByRating(stocks []models.Stock, minRating string, topN int) []StockRecommendation {
-	filtered := make([]models.Stock, 0)
-	ratingValues := map[string]int{
-		"Buy":            5,
-		"Outperform":     4,
-		"Overweight":     4,
-		"Equal Weight":   3,
-		"Sector Perform": 3,
-		"Hold":           2,
-		"Underperform":   1,
-		"Sell":           0,
-	}
+func (s *StockService) GetTopStocksByRating(ctx context.Context, stocks []repositories.StockWithPrice, minRating string, topN int) ([]StockRecommendation, error) {
+	filtered := make([]repositories.StockWithPrice, 0)
 
-	minRatingValue, exists := ratingValues[minRating]
+	minRatingValue, exists := RatingScores[minRating]
 	if !exists {
-		minRatingValue = 3
+		return nil, apperrors.InvalidField("rating", fmt.Sprintf("unknown rating %q", minRating))
 	}
 
 	for _, stock := range stocks {
-		stockRatingValue, exists := ratingValues[stock.RatingTo]
+		stockRatingValue, exists := RatingScores[stock.RatingTo]
 		if exists && stockRatingValue >= minRatingValue {
 			filtered = append(filtered, stock)
 		}
 	}
 
-	return recommendStocks(filtered, topN)
+	return scoreWithSpan(ctx, filtered, topN, DefaultScoringProfile), nil
+}
+
+func scoreStock(stock repositories.StockWithPrice) StockRecommendation {
+	return scoreStockWithProfile(stock, DefaultScoringProfile, time.Now())
 }
 
-func scoreStock(stock models.Stock) StockRecommendation {
+// scoreStockWithProfile scores a stock as seen at asOf, so the recency bonus
+// can be replayed for past dates.
+func scoreStockWithProfile(stock repositories.StockWithPrice, profile ScoringProfile, asOf time.Time) StockRecommendation {
 	var score float64 = 0
 
 	targetChange := stock.TargetTo - stock.TargetFrom
 	var targetChangePercent float64 = 0
 	if stock.TargetFrom > 0 {
 		targetChangePercent = (targetChange / stock.TargetFrom) * 100
-		score += targetChangePercent * TargetChangePercentMultiplier
+		score += targetChangePercent * profile.TargetChangePercentMultiplier
 	}
 
 	if ratingScore, exists := RatingScores[stock.RatingTo]; exists {
-		score += ratingScore
+		score += ratingScore * profile.RatingMultiplier
 	}
 
 	switch stock.Action {
-	case "target raised by":
-		score += 3
-	case "upgraded by":
-		score += 5
-	case "reiterated by":
-		score += 1
-	case "target lowered by":
-		score -= 2
-	case "downgraded by":
-		score -= 4
+	case ActionTargetRaised:
+		score += profile.ActionTargetRaisedScore
+	case ActionUpgraded:
+		score += profile.ActionUpgradedScore
+	case ActionReiterated:
+		score += profile.ActionReiteratedScore
+	case ActionTargetLowered:
+		score += profile.ActionTargetLoweredScore
+	case ActionDowngraded:
+		score += profile.ActionDowngradedScore
 	}
 
 	if stock.TargetTo > 0 {
-		relativeTargetScore := math.Log(stock.TargetTo) * TargetPriceLogMultiplier
+		relativeTargetScore := math.Log(stock.TargetTo) * profile.TargetPriceLogMultiplier
 		score += relativeTargetScore
 	}
 
+	upside := models.ImpliedUpside(stock.TargetTo, stock.LastClose)
+	if upside != nil {
+		score += *upside * 100 * profile.UpsidePercentMultiplier
+	}
+
 	updateTime := stock.UpdatedAt
-	now := time.Now()
-	daysDifference := int(now.Sub(updateTime).Hours() / 24)
+	daysDifference := int(asOf.Sub(updateTime).Hours() / 24)
 
-	if daysDifference <= RecentUpdateDaysThreshold {
-		score += 2
-	} else if daysDifference <= ModeratelyRecentUpdateDaysThreshold {
-		score += 1
+	if daysDifference <= profile.RecentUpdateDaysThreshold {
+		score += profile.RecentUpdateScore
+	} else if daysDifference <= profile.ModeratelyRecentUpdateDaysThreshold {
+		score += profile.ModeratelyRecentUpdateScore
 	}
 
 	return StockRecommendation{
@@ -211,5 +336,7 @@ func scoreStock(stock models.Stock) StockRecommendation {
 		TargetPrice:   stock.TargetTo,
 		Action:        stock.Action,
 		ChangePercent: targetChangePercent,
+		LastClose:     stock.LastClose,
+		Upside:        upside,
 	}
 }
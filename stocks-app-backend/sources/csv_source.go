@@ -0,0 +1,74 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/services"
+)
+
+const CSVSourceName = "csv"
+
+// CSVSource reads every *.csv file in a drop folder, in the same format as
+// the admin import. Files are read again on every sync, rows that did not
+// change are left alone, so a file stays in effect until it is removed.
+type CSVSource struct {
+	dir string
+}
+
+func NewCSVSource(dir string) *CSVSource {
+	return &CSVSource{dir: dir}
+}
+
+func (s *CSVSource) Name() string {
+	return CSVSourceName
+}
+
+func (s *CSVSource) FetchStocks(ctx context.Context) ([]models.Stock, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.csv"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing rating files: %w", err)
+	}
+
+	stocks := []models.Stock{}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		fileStocks, err := readRatingsFile(ctx, file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", file, err)
+		}
+		stocks = append(stocks, fileStocks...)
+	}
+
+	slog.InfoContext(ctx, "Loaded rating files", slog.Int("rows", len(stocks)), slog.Int("files", len(files)), slog.String("dir", s.dir))
+	return stocks, nil
+}
+
+func readRatingsFile(ctx context.Context, path string) ([]models.Stock, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	report, stocks, err := services.ReadStocksCSV(f, services.ImportOptions{Source: CSVSourceName})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range report.Rows {
+		if row.Status == services.ImportRowInvalid {
+			slog.WarnContext(ctx, "Skipping invalid rating row",
+				slog.String("file", filepath.Base(path)), slog.Int("row", row.Row), slog.Any("errors", row.Errors))
+		}
+	}
+
+	return stocks, nil
+}
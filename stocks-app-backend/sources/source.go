@@ -0,0 +1,126 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/felipepalacio293/stocks-app/clients"
+	"github.com/felipepalacio293/stocks-app/config"
+	"github.com/felipepalacio293/stocks-app/models"
+)
+
+// RatingSource is a provider of analyst ratings. Each source is synced on its
+// own and its rows are stored under its name, so sources never overwrite each
+// other's ratings.
+type RatingSource interface {
+	Name() string
+	FetchStocks(ctx context.Context) ([]models.Stock, error)
+}
+
+// Pinger is implemented by sources that can report whether they are reachable
+// without a full fetch.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ProgressReporter is implemented by paginated sources.
+type ProgressReporter interface {
+	OnPage(fn func(page, items int))
+}
+
+type Registry struct {
+	sources map[string]RatingSource
+}
+
+func NewRegistry() *Registry {
+	return &Registry{sources: make(map[string]RatingSource)}
+}
+
+func (r *Registry) Register(source RatingSource) error {
+	if _, ok := r.sources[source.Name()]; ok {
+		return fmt.Errorf("rating source %s is already registered", source.Name())
+	}
+	r.sources[source.Name()] = source
+	return nil
+}
+
+func (r *Registry) Get(name string) (RatingSource, bool) {
+	source, ok := r.sources[name]
+	return source, ok
+}
+
+// All returns the sources sorted by name.
+func (r *Registry) All() []RatingSource {
+	all := make([]RatingSource, 0, len(r.sources))
+	for _, name := range r.Names() {
+		all = append(all, r.sources[name])
+	}
+	return all
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.sources))
+	for name := range r.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FromConfig builds the sources listed in RATING_SOURCES.
+func FromConfig(cfg *config.Config) (*Registry, error) {
+	registry := NewRegistry()
+
+	for _, name := range cfg.RatingSources {
+		var source RatingSource
+		switch name {
+		case clients.APISourceName:
+			client, err := apiClientFromConfig(cfg)
+			if err != nil {
+				return nil, err
+			}
+			source = client
+		case clients.VendorSourceName:
+			if cfg.VendorAPIURL == "" {
+				return nil, fmt.Errorf("rating source %s needs VENDOR_API_URL", name)
+			}
+			source = clients.NewVendorClient(cfg.VendorAPIURL, cfg.VendorAPIKey)
+		case CSVSourceName:
+			if cfg.RatingsDir == "" {
+				return nil, fmt.Errorf("rating source %s needs RATINGS_DIR", name)
+			}
+			source = NewCSVSource(cfg.RatingsDir)
+		default:
+			return nil, fmt.Errorf("unknown rating source %q", name)
+		}
+
+		if err := registry.Register(source); err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+// apiClientFromConfig builds the challenge API client, recording or replaying
+// its pages when UPSTREAM_MODE asks for it. Replays need no API_BASE_URL.
+func apiClientFromConfig(cfg *config.Config) (*clients.APIClient, error) {
+	if cfg.UpstreamMode != config.UpstreamModeReplay && cfg.APIBaseURL == "" {
+		return nil, fmt.Errorf("rating source %s needs API_BASE_URL", clients.APISourceName)
+	}
+
+	client := clients.NewAPIClient(cfg.APIBaseURL, cfg.APIKey)
+	switch cfg.UpstreamMode {
+	case config.UpstreamModeRecord:
+		client.SetTransport(clients.NewRecordingTransport(cfg.UpstreamFixturesDir, http.DefaultTransport))
+	case config.UpstreamModeReplay:
+		if _, err := os.Stat(cfg.UpstreamFixturesDir); err != nil {
+			return nil, fmt.Errorf("replaying upstream fixtures: %w", err)
+		}
+		client.SetTransport(clients.NewReplayTransport(cfg.UpstreamFixturesDir))
+	}
+	return client, nil
+}
@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/cache"
+	"github.com/felipepalacio293/stocks-app/clients"
+	"github.com/felipepalacio293/stocks-app/repositories"
+)
+
+type PriceLoadTask struct {
+	priceRepo *repositories.PriceRepository
+	provider  clients.PriceProvider
+	cache     *cache.ResponseCache
+	interval  time.Duration
+}
+
+func NewPriceLoadTask(priceRepo *repositories.PriceRepository, provider clients.PriceProvider, responseCache *cache.ResponseCache, interval time.Duration) *PriceLoadTask {
+	return &PriceLoadTask{
+		priceRepo: priceRepo,
+		provider:  provider,
+		cache:     responseCache,
+		interval:  interval,
+	}
+}
+
+func (t *PriceLoadTask) Start(ctx context.Context) {
+	t.LoadPrices(ctx)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.LoadPrices(ctx)
+		case <-ctx.Done():
+			slog.Info("Price load task stopped")
+			return
+		}
+	}
+}
+
+func (t *PriceLoadTask) LoadPrices(ctx context.Context) {
+	slog.InfoContext(ctx, "Loading price history")
+
+	prices, err := t.provider.FetchPrices(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching prices", slog.Any("error", err))
+		return
+	}
+
+	if err := t.priceRepo.Upsert(ctx, prices, 500); err != nil {
+		slog.ErrorContext(ctx, "Error storing prices", slog.Any("error", err))
+		return
+	}
+	// Upside depends on the latest close
+	t.cache.Invalidate()
+
+	slog.InfoContext(ctx, "Successfully loaded price history", slog.Int("bars", len(prices)))
+}
@@ -2,58 +2,328 @@ package tasks
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"sync"
 	"time"
 
-	"github.com/felipepalacio293/stocks-app/clients"
+	"github.com/felipepalacio293/stocks-app/cache"
+	"github.com/felipepalacio293/stocks-app/logging"
+	"github.com/felipepalacio293/stocks-app/metrics"
 	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/schedule"
+	"github.com/felipepalacio293/stocks-app/services"
+	"github.com/felipepalacio293/stocks-app/sources"
+	"github.com/felipepalacio293/stocks-app/tracing"
+	"github.com/felipepalacio293/stocks-app/utils"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = tracing.Tracer("github.com/felipepalacio293/stocks-app/tasks")
+
 type StockSyncTask struct {
 	stockRepo *repositories.StockRepository
-	apiClient *clients.APIClient
-	interval  time.Duration
+	registry  *sources.Registry
+	eventHub  *services.StockEventHub
+	cache     *cache.ResponseCache
+
+	mu        sync.Mutex
+	startedAt time.Time
+	schedules map[string]schedule.Schedule
+	statuses  map[string]*SourceStatus
 }
 
-func NewStockSyncTask(stockRepo *repositories.StockRepository, apiClient *clients.APIClient, interval time.Duration) *StockSyncTask {
+// SourceStatus is the sync state of one rating source.
+type SourceStatus struct {
+	Source        string      `json:"source"`
+	Running       bool        `json:"running"`
+	Schedule      string      `json:"schedule,omitempty"`
+	NextRunAt     *time.Time  `json:"next_run_at,omitempty"`
+	LastRunAt     *time.Time  `json:"last_run_at,omitempty"`
+	LastSuccessAt *time.Time  `json:"last_success_at,omitempty"`
+	LastError     string      `json:"last_error,omitempty"`
+	LastResult    *SyncResult `json:"last_result,omitempty"`
+}
+
+// NewStockSyncTask syncs every source of registry on defaultSchedule. A nil
+// defaultSchedule is for one-off syncs, Start then syncs each source once and
+// waits for ctx unless SetSchedule gave it a schedule of its own.
+func NewStockSyncTask(stockRepo *repositories.StockRepository, registry *sources.Registry, eventHub *services.StockEventHub, responseCache *cache.ResponseCache, defaultSchedule schedule.Schedule) *StockSyncTask {
+	schedules := make(map[string]schedule.Schedule)
+	statuses := make(map[string]*SourceStatus)
+	for _, name := range registry.Names() {
+		schedules[name] = defaultSchedule
+		statuses[name] = &SourceStatus{Source: name}
+		if defaultSchedule != nil {
+			statuses[name].Schedule = defaultSchedule.String()
+		}
+	}
+
 	return &StockSyncTask{
 		stockRepo: stockRepo,
-		apiClient: apiClient,
-		interval:  interval,
+		registry:  registry,
+		eventHub:  eventHub,
+		cache:     responseCache,
+		schedules: schedules,
+		statuses:  statuses,
 	}
 }
 
+// SetSchedule overrides the schedule of one source, before Start.
+func (t *StockSyncTask) SetSchedule(name string, sourceSchedule schedule.Schedule) error {
+	if _, ok := t.registry.Get(name); !ok {
+		return fmt.Errorf("unknown rating source %q", name)
+	}
+	if sourceSchedule == nil {
+		return fmt.Errorf("no schedule given for rating source %q", name)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.schedules[name] = sourceSchedule
+	t.statuses[name].Schedule = sourceSchedule.String()
+	return nil
+}
+
+// Start syncs every source right away and then on its schedule. Sources run
+// independently, a slow or failing one does not hold the others back.
 func (t *StockSyncTask) Start(ctx context.Context) {
-	t.SyncStocks(ctx)
+	t.mu.Lock()
+	t.startedAt = time.Now()
+	t.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, source := range t.registry.All() {
+		wg.Add(1)
+		go func(source sources.RatingSource) {
+			defer wg.Done()
+			t.run(ctx, source)
+		}(source)
+	}
+	wg.Wait()
+
+	slog.Info("Stock sync task stopped")
+}
+
+func (t *StockSyncTask) run(ctx context.Context, source sources.RatingSource) {
+	name := source.Name()
+	t.mu.Lock()
+	sourceSchedule := t.schedules[name]
+	t.mu.Unlock()
 
-	ticker := time.NewTicker(t.interval)
-	defer ticker.Stop()
+	t.SyncSource(ctx, source)
+
+	if sourceSchedule == nil {
+		<-ctx.Done()
+		return
+	}
 
 	for {
+		// Scheduled from the end of the run, a sync slower than an interval
+		// skips the runs it overlapped instead of queueing them
+		next := sourceSchedule.Next(time.Now())
+		if next.IsZero() {
+			slog.WarnContext(ctx, "No upcoming sync in the schedule", slog.String("source", name), slog.String("schedule", sourceSchedule.String()))
+			t.updateStatus(name, func(status *SourceStatus) { status.NextRunAt = nil })
+			<-ctx.Done()
+			return
+		}
+		t.updateStatus(name, func(status *SourceStatus) { status.NextRunAt = &next })
+		slog.DebugContext(ctx, "Next sync scheduled", slog.String("source", name), slog.Time("at", next))
+
+		timer := time.NewTimer(time.Until(next))
 		select {
-		case <-ticker.C:
-			t.SyncStocks(ctx)
+		case <-timer.C:
+			t.SyncSource(ctx, source)
 		case <-ctx.Done():
-			log.Println("Stock sync task stopped")
+			timer.Stop()
 			return
 		}
 	}
 }
 
-func (t *StockSyncTask) SyncStocks(ctx context.Context) {
-	log.Println("Syncing stocks from API...")
+// Overdue returns the source furthest behind its schedule and how long ago
+// its schedule wanted it synced again: the first run planned after its last
+// success, or after Start while it never succeeded. Measuring against the
+// schedule keeps long planned gaps, like nights and weekends, from counting as
+// late. The duration is zero or negative while every source is on time, and
+// before Start.
+func (t *StockSyncTask) Overdue(now time.Time) (string, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var (
+		worst string
+		late  time.Duration
+	)
+	for _, name := range t.registry.Names() {
+		sourceSchedule := t.schedules[name]
+		since := t.startedAt
+		if status := t.statuses[name]; status.LastSuccessAt != nil {
+			since = *status.LastSuccessAt
+		}
+		if sourceSchedule == nil || since.IsZero() {
+			continue
+		}
+
+		due := sourceSchedule.Next(since)
+		if due.IsZero() {
+			continue
+		}
+		if behind := now.Sub(due); worst == "" || behind > late {
+			worst, late = name, behind
+		}
+	}
+	return worst, late
+}
+
+// Status returns a copy of every source's sync state, sorted by source name.
+func (t *StockSyncTask) Status() []SourceStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statuses := make([]SourceStatus, 0, len(t.statuses))
+	for _, name := range t.registry.Names() {
+		statuses = append(statuses, *t.statuses[name])
+	}
+	return statuses
+}
+
+type SyncResult struct {
+	RunID     string        `json:"run_id"`
+	Source    string        `json:"source"`
+	Fetched   int           `json:"fetched"`
+	Inserted  int           `json:"inserted"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Sync runs one sync of the named source.
+func (t *StockSyncTask) Sync(ctx context.Context, name string) (*SyncResult, error) {
+	source, ok := t.registry.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown rating source %q", name)
+	}
+	return t.SyncSource(ctx, source)
+}
+
+// SyncSource runs one sync of source. The result is returned even on a store
+// error and then covers the batches that were committed.
+func (t *StockSyncTask) SyncSource(ctx context.Context, source sources.RatingSource) (*SyncResult, error) {
+	name := source.Name()
+	runID := uuid.NewString()
+	ctx = logging.WithContext(ctx, slog.String("sync_run_id", runID), slog.String("source", name))
+	// Upstream calls carry the run ID so the vendor can correlate them with us
+	ctx = utils.WithRequestID(ctx, runID)
+
+	ctx, span := tracer.Start(ctx, "StockSyncTask.SyncSource")
+	defer span.End()
+	span.SetAttributes(attribute.String("sync.run_id", runID), attribute.String("sync.source", name))
 
-	stocks, err := t.apiClient.FetchStocks(ctx)
+	slog.InfoContext(ctx, "Syncing stocks from rating source")
+
+	start := time.Now()
+	t.updateStatus(name, func(status *SourceStatus) {
+		status.Running = true
+		status.LastRunAt = &start
+	})
+	defer func() {
+		metrics.SyncDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}()
+
+	stocks, err := source.FetchStocks(ctx)
 	if err != nil {
-		log.Printf("Error fetching stocks: %v", err)
-		return
+		slog.ErrorContext(ctx, "Error fetching stocks", slog.Any("error", err))
+		tracing.RecordError(span, err)
+		metrics.SyncRunsTotal.WithLabelValues(name, "fetch_error").Inc()
+		err = fmt.Errorf("error fetching stocks from %s: %w", name, err)
+		t.finishStatus(name, nil, err)
+		return nil, err
+	}
+
+	for i := range stocks {
+		stocks[i].Source = name
+	}
+
+	result, err := t.stockRepo.BatchInsert(ctx, stocks, 100)
+	if t.eventHub != nil {
+		t.eventHub.Publish(result.Changes)
+	}
+	if len(result.Changes) > 0 {
+		t.cache.Invalidate()
+	}
+	span.SetAttributes(
+		attribute.Int("sync.fetched", len(stocks)),
+		attribute.Int("sync.inserted", result.Inserted),
+		attribute.Int("sync.updated", result.Updated),
+	)
+	metrics.SyncRowsTotal.WithLabelValues(name, "inserted").Add(float64(result.Inserted))
+	metrics.SyncRowsTotal.WithLabelValues(name, "updated").Add(float64(result.Updated))
+	metrics.SyncRowsTotal.WithLabelValues(name, "unchanged").Add(float64(result.Unchanged))
+
+	syncResult := &SyncResult{
+		RunID:     runID,
+		Source:    name,
+		Fetched:   len(stocks),
+		Inserted:  result.Inserted,
+		Updated:   result.Updated,
+		Unchanged: result.Unchanged,
+		Duration:  time.Since(start),
 	}
 
-	err = t.stockRepo.BatchInsert(ctx, stocks, 100)
 	if err != nil {
-		log.Printf("Error storing stocks: %v", err)
-		return
+		slog.ErrorContext(ctx, "Error storing stocks", slog.Any("error", err))
+		tracing.RecordError(span, err)
+		metrics.SyncRunsTotal.WithLabelValues(name, "store_error").Inc()
+		err = fmt.Errorf("error storing stocks from %s: %w", name, err)
+		t.finishStatus(name, syncResult, err)
+		return syncResult, err
+	}
+
+	if err := services.KnownBrokerages.Load(ctx, t.stockRepo); err != nil {
+		slog.WarnContext(ctx, "Error reloading brokerages", slog.Any("error", err))
 	}
 
-	log.Printf("Successfully synced %d stocks", len(stocks))
+	metrics.SyncRunsTotal.WithLabelValues(name, "success").Inc()
+	metrics.SyncLastSuccessTimestamp.WithLabelValues(name).SetToCurrentTime()
+	t.finishStatus(name, syncResult, nil)
+
+	slog.InfoContext(ctx, "Successfully synced stocks",
+		slog.Int("fetched", syncResult.Fetched),
+		slog.Int("inserted", syncResult.Inserted),
+		slog.Int("updated", syncResult.Updated),
+		slog.Int("unchanged", syncResult.Unchanged),
+		slog.Duration("duration", syncResult.Duration))
+
+	return syncResult, nil
+}
+
+func (t *StockSyncTask) finishStatus(name string, result *SyncResult, err error) {
+	t.updateStatus(name, func(status *SourceStatus) {
+		status.Running = false
+		if result != nil {
+			status.LastResult = result
+		}
+		if err != nil {
+			status.LastError = err.Error()
+			return
+		}
+		now := time.Now()
+		status.LastSuccessAt = &now
+		status.LastError = ""
+	})
+}
+
+func (t *StockSyncTask) updateStatus(name string, fn func(*SourceStatus)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status, ok := t.statuses[name]
+	if !ok {
+		status = &SourceStatus{Source: name}
+		t.statuses[name] = status
+	}
+	fn(status)
 }
@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/felipepalacio293/stocks-app/models"
+	"github.com/felipepalacio293/stocks-app/repositories"
+	"github.com/felipepalacio293/stocks-app/schedule"
+	"github.com/felipepalacio293/stocks-app/sources"
+)
+
+type failingSource struct {
+	name  string
+	calls chan struct{}
+}
+
+func (s *failingSource) Name() string {
+	return s.name
+}
+
+func (s *failingSource) FetchStocks(ctx context.Context) ([]models.Stock, error) {
+	s.calls <- struct{}{}
+	return nil, errors.New("upstream is down")
+}
+
+func TestStockSyncTaskWithoutSchedule(t *testing.T) {
+	cases := []struct {
+		name     string
+		schedule schedule.Schedule
+		override schedule.Schedule
+		wantRuns int
+	}{
+		{"syncs once", nil, nil, 1},
+		{"source schedule", nil, schedule.Every(10 * time.Millisecond), 3},
+		{"default schedule", schedule.Every(10 * time.Millisecond), nil, 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := &failingSource{name: "test", calls: make(chan struct{}, 10)}
+			registry := sources.NewRegistry()
+			if err := registry.Register(source); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+
+			task := NewStockSyncTask(repositories.NewStockRepository(nil), registry, nil, nil, tc.schedule)
+			if err := task.SetSchedule(source.name, nil); err == nil {
+				t.Error("SetSchedule accepted a nil schedule")
+			}
+			if tc.override != nil {
+				if err := task.SetSchedule(source.name, tc.override); err != nil {
+					t.Fatalf("SetSchedule: %v", err)
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				task.Start(ctx)
+			}()
+
+			for i := 0; i < tc.wantRuns; i++ {
+				select {
+				case <-source.calls:
+				case <-time.After(time.Second):
+					t.Fatalf("got %d syncs, want at least %d", i, tc.wantRuns)
+				}
+			}
+			if tc.wantRuns == 1 {
+				select {
+				case <-source.calls:
+					t.Error("synced again without a schedule")
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("Start did not return after ctx was cancelled")
+			}
+		})
+	}
+}
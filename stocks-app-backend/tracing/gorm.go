@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "otel:span"
+
+// GormPlugin starts a span for every GORM operation, as a child of the span
+// in the statement context (set with db.WithContext).
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "otel-tracing"
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	operations := []struct {
+		name     string
+		register func(before, after func(*gorm.DB)) error
+	}{
+		{"create", func(before, after func(*gorm.DB)) error {
+			if err := cb.Create().Before("gorm:create").Register("otel:before_create", before); err != nil {
+				return err
+			}
+			return cb.Create().After("gorm:create").Register("otel:after_create", after)
+		}},
+		{"query", func(before, after func(*gorm.DB)) error {
+			if err := cb.Query().Before("gorm:query").Register("otel:before_query", before); err != nil {
+				return err
+			}
+			return cb.Query().After("gorm:query").Register("otel:after_query", after)
+		}},
+		{"update", func(before, after func(*gorm.DB)) error {
+			if err := cb.Update().Before("gorm:update").Register("otel:before_update", before); err != nil {
+				return err
+			}
+			return cb.Update().After("gorm:update").Register("otel:after_update", after)
+		}},
+		{"delete", func(before, after func(*gorm.DB)) error {
+			if err := cb.Delete().Before("gorm:delete").Register("otel:before_delete", before); err != nil {
+				return err
+			}
+			return cb.Delete().After("gorm:delete").Register("otel:after_delete", after)
+		}},
+		{"row", func(before, after func(*gorm.DB)) error {
+			if err := cb.Row().Before("gorm:row").Register("otel:before_row", before); err != nil {
+				return err
+			}
+			return cb.Row().After("gorm:row").Register("otel:after_row", after)
+		}},
+		{"raw", func(before, after func(*gorm.DB)) error {
+			if err := cb.Raw().Before("gorm:raw").Register("otel:before_raw", before); err != nil {
+				return err
+			}
+			return cb.Raw().After("gorm:raw").Register("otel:after_raw", after)
+		}},
+	}
+
+	for _, op := range operations {
+		if err := op.register(p.before(op.name), p.after); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (GormPlugin) before(operation string) func(*gorm.DB) {
+	tracer := Tracer("gorm.io/gorm")
+
+	return func(db *gorm.DB) {
+		ctx, span := tracer.Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.operation", operation),
+			))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func (GormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		RecordError(span, db.Error)
+	}
+}
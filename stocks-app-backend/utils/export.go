@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// ExportRow is implemented by every type that can be written by an ExportWriter.
+type ExportRow interface {
+	CSVHeader() []string
+	CSVRecord() []string
+}
+
+type ExportWriter interface {
+	Write(row ExportRow) error
+	// WriteError marks the output as incomplete where the format allows it,
+	// NDJSON ends with an {"error": ...} line while CSV has no place for one.
+	WriteError(message string) error
+	Flush() error
+}
+
+func NewExportWriter(format string, w io.Writer) (ExportWriter, error) {
+	switch strings.ToLower(format) {
+	case ExportFormatCSV:
+		return &csvExportWriter{writer: csv.NewWriter(w)}, nil
+	case ExportFormatNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, apperrors.InvalidField("format", fmt.Sprintf("unsupported export format: %s", format))
+	}
+}
+
+// FormatOptionalFloat renders a nullable number for a CSV column, nil as an
+// empty cell.
+func FormatOptionalFloat(value *float64, precision int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', precision, 64)
+}
+
+func ExportContentType(format string) string {
+	if strings.ToLower(format) == ExportFormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func ExportFileExtension(format string) string {
+	if strings.ToLower(format) == ExportFormatNDJSON {
+		return ExportFormatNDJSON
+	}
+	return ExportFormatCSV
+}
+
+type csvExportWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvExportWriter) Write(row ExportRow) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(row.CSVHeader()); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+	return w.writer.Write(row.CSVRecord())
+}
+
+func (w *csvExportWriter) WriteError(message string) error {
+	return nil
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonExportWriter) Write(row ExportRow) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonExportWriter) WriteError(message string) error {
+	return w.encoder.Encode(map[string]string{"error": message})
+}
+
+func (w *ndjsonExportWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParsePrice parses upstream price strings such as "$1,234.50". NaN and
+// infinities are rejected, they cannot be encoded as JSON.
+func ParsePrice(value string) (float64, error) {
+	clean := strings.ReplaceAll(strings.TrimPrefix(strings.TrimSpace(value), "$"), ",", "")
+	price, err := strconv.ParseFloat(clean, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(price) || math.IsInf(price, 0) {
+		return 0, fmt.Errorf("price %q is not a finite number", value)
+	}
+	return price, nil
+}
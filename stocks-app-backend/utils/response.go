@@ -1,11 +1,19 @@
 package utils
 
+import (
+	"context"
+	"net/http"
+
+	"github.com/felipepalacio293/stocks-app/apperrors"
+)
+
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-	Meta    interface{} `json:"meta,omitempty"`
+	Success   bool        `json:"success"`
+	Message   string      `json:"message,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Meta      interface{} `json:"meta,omitempty"`
 }
 
 type PaginationMeta struct {
@@ -15,10 +23,11 @@ type PaginationMeta struct {
 	TotalPages  int   `json:"total_pages"`
 }
 
-func ErrorResponse(message string) Response {
+func ErrorResponse(ctx context.Context, message string) Response {
 	return Response{
-		Success: false,
-		Error:   message,
+		Success:   false,
+		Error:     message,
+		RequestID: RequestIDFromContext(ctx),
 	}
 }
 
@@ -48,3 +57,31 @@ func PaginatedResponse(data interface{}, page, pageSize int, total int64, messag
 		},
 	}
 }
+
+// Problem is an RFC 7807 error body, served as application/problem+json.
+type Problem struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	Code      string                 `json:"code"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Errors    []apperrors.FieldError `json:"errors,omitempty"`
+	Data      interface{}            `json:"data,omitempty"`
+}
+
+func ProblemResponse(ctx context.Context, err *apperrors.Error, instance string) Problem {
+	status := err.Kind.Status()
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    err.Message,
+		Instance:  instance,
+		Code:      string(err.Kind),
+		RequestID: RequestIDFromContext(ctx),
+		Errors:    err.Fields,
+		Data:      err.Data,
+	}
+}